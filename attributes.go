@@ -0,0 +1,12 @@
+package sdiscovery
+
+// Determine if the attributes contain each of the key/value pairs in the
+// filter. An empty filter matches any set of attributes.
+func matchAttributes(attrs, filter map[string]string) bool {
+	for k, v := range filter {
+		if a, ok := attrs[k]; !ok || a != v {
+			return false
+		}
+	}
+	return true
+}
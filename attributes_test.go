@@ -0,0 +1,29 @@
+package sdiscovery
+
+import (
+	"testing"
+)
+
+// Ensure that attributes are correctly matched against a filter.
+func Test_matchAttributes(t *testing.T) {
+
+	attrs := map[string]string{"role": "worker", "zone": "a"}
+
+	// An empty filter should match anything.
+	if !matchAttributes(attrs, nil) {
+		t.Fatal("Empty filter should match")
+	}
+
+	// A subset of the attributes should match.
+	if !matchAttributes(attrs, map[string]string{"role": "worker"}) {
+		t.Fatal("Subset of attributes should match")
+	}
+
+	// A differing value or missing key should not match.
+	if matchAttributes(attrs, map[string]string{"role": "coordinator"}) {
+		t.Fatal("Differing value should not match")
+	}
+	if matchAttributes(attrs, map[string]string{"rack": "1"}) {
+		t.Fatal("Missing key should not match")
+	}
+}
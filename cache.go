@@ -0,0 +1,105 @@
+package sdiscovery
+
+import (
+	"github.com/nathan-osman/go-sdiscovery/peer"
+	"github.com/nathan-osman/go-sdiscovery/store"
+)
+
+// Restore the peers from the store as stale peers. Peers that are too old or
+// have no addresses, the ID of this service or attributes that do not match
+// the filter are skipped.
+func (s *Service) loadPeers() {
+
+	cached, err := s.config.Store.Load()
+	if err != nil {
+		s.config.Logger.Warn("unable to load peers", "error", err)
+		return
+	}
+
+	// Obtain exclusive access to the map while it is updated.
+	s.mutex.Lock()
+
+	// Avoid repeated calls to Now() by invoking it once here.
+	curTime := s.config.Clock.Now()
+
+	// Determine the age beyond which peers are considered gone.
+	maxAge := s.config.StoreMaxAge
+	if maxAge == 0 {
+		maxAge = defaultStoreMaxAge
+	}
+
+	// Add each of the peers that is not already known.
+	var added []string
+	for _, c := range cached {
+		if curTime.Sub(c.LastSeen) > maxAge || len(c.Addrs) == 0 || c.ID == s.config.ID ||
+			!matchAttributes(c.Attributes, s.config.AttributeFilter) {
+			continue
+		}
+		if _, exists := s.peers[c.ID]; exists {
+			continue
+		}
+		addrs := make([]peer.AddrInfo, len(c.Addrs))
+		for i, addr := range c.Addrs {
+			addrs[i] = peer.AddrInfo{IP: addr.IP, Interface: addr.Interface}
+		}
+		p := &peer.Peer{}
+		p.Restore(c.UserData, c.Attributes, addrs, c.LastSeen, curTime)
+		s.peers[c.ID] = p
+		added = append(added, c.ID)
+		s.config.Metrics.PeerAdded(c.ID)
+	}
+	if len(added) != 0 {
+		s.config.Metrics.PeerCount(len(s.peers))
+		s.notifyChange()
+	}
+
+	s.mutex.Unlock()
+
+	// Send each of the peer IDs over the PeerAdded channel.
+	for _, id := range added {
+		s.config.Logger.Debug("peer restored", "peer", id)
+		s.emit(Event{Type: EventPeerAdded, Time: curTime, PeerID: id})
+		select {
+		case s.PeerAdded <- id:
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// Write the current peers to the store. Unless force is set, nothing is
+// written if the peers have not changed since they were last written. Pings
+// alone do not count as changes, so the times the peers were last seen are
+// only current after a forced save.
+func (s *Service) savePeers(force bool) {
+
+	// Determine whether any changes were made, clearing the flag before the
+	// snapshot is taken so that later changes are not lost.
+	s.mutex.Lock()
+	dirty := s.dirty
+	s.dirty = false
+	s.mutex.Unlock()
+	if !dirty && !force {
+		return
+	}
+
+	// Write the peers without holding the mutex, since the store may block.
+	infos := s.snapshot()
+	peers := make([]store.Peer, len(infos))
+	for i, info := range infos {
+		addrs := make([]store.Addr, len(info.Addrs))
+		for j, addr := range info.Addrs {
+			addrs[j] = store.Addr{IP: addr.IP, Interface: addr.Interface}
+		}
+		peers[i] = store.Peer{
+			ID:         info.ID,
+			UserData:   info.UserData,
+			Attributes: info.Attributes,
+			Addrs:      addrs,
+			LastSeen:   info.LastSeen,
+		}
+	}
+	if err := s.config.Store.Save(peers); err != nil {
+		s.config.Logger.Warn("unable to save peers", "error", err)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery"
+)
+
+// Create a channel that receives a value when the process is interrupted.
+func interrupted() <-chan os.Signal {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	return c
+}
+
+// Discard peer notifications from the service, which would otherwise block,
+// until done is closed.
+func drain(s *sdiscovery.Service, done <-chan struct{}) {
+	for {
+		select {
+		case <-s.PeerAdded:
+		case <-s.PeerRemoved:
+		case <-done:
+			return
+		}
+	}
+}
+
+// Find the specified peer, returning an entry with only the ID if it no longer
+// exists.
+func findPeer(s *sdiscovery.Service, id string) sdiscovery.PeerInfo {
+	for _, info := range s.Peers() {
+		if info.ID == id {
+			return info
+		}
+	}
+	return sdiscovery.PeerInfo{ID: id}
+}
+
+// Announce the service until interrupted.
+func announce(args []string) error {
+	o := newOptions("announce")
+	o.flags.Parse(args)
+
+	s := sdiscovery.New(o.config())
+	defer s.Stop()
+	done := make(chan struct{})
+	defer close(done)
+	go drain(s, done)
+
+	fmt.Fprintf(os.Stderr, "Announcing %s on port %d\n", o.id, o.port)
+	<-interrupted()
+	return nil
+}
+
+// Print peers as they are added, updated and removed until interrupted.
+func browse(args []string) error {
+	o := newOptions("browse")
+	o.flags.Parse(args)
+
+	// Receive events from the service, dropping them if output falls
+	// behind rather than blocking the service.
+	events := make(chan sdiscovery.Event, 100)
+	config := o.config()
+	config.Hook = sdiscovery.HookFunc(func(e sdiscovery.Event) {
+		switch e.Type {
+		case sdiscovery.EventPeerAdded, sdiscovery.EventPeerUpdated, sdiscovery.EventPeerRemoved:
+			select {
+			case events <- e:
+			default:
+			}
+		}
+	})
+
+	s := sdiscovery.New(config)
+	defer s.Stop()
+	done := make(chan struct{})
+	defer close(done)
+	go drain(s, done)
+
+	signalChan := interrupted()
+	for {
+		select {
+		case e := <-events:
+			var out peerOutput
+			switch e.Type {
+			case sdiscovery.EventPeerAdded:
+				out = newPeerOutput(findPeer(s, e.PeerID))
+				out.Event = "added"
+			case sdiscovery.EventPeerUpdated:
+				out = newPeerOutput(findPeer(s, e.PeerID))
+				out.Event = "updated"
+			case sdiscovery.EventPeerRemoved:
+				out = peerOutput{ID: e.PeerID, Event: "removed"}
+			}
+			t := e.Time
+			out.Time = &t
+			if err := writePeer(os.Stdout, out, o.json); err != nil {
+				return err
+			}
+		case <-signalChan:
+			return nil
+		}
+	}
+}
+
+// List the peers found within a period of time and exit. An error is returned
+// if no peers were found so that the command can be used in scripts.
+func probe(args []string) error {
+	o := newOptions("probe")
+	wait := o.flags.Duration("wait", 3*time.Second, "time to wait for peers")
+	o.flags.Parse(args)
+
+	s := sdiscovery.New(o.config())
+	defer s.Stop()
+	done := make(chan struct{})
+	defer close(done)
+	go drain(s, done)
+
+	select {
+	case <-time.After(*wait):
+	case <-interrupted():
+	}
+
+	// Write the peers as a single JSON array or one per line.
+	outputs := []peerOutput{}
+	for _, info := range s.Peers() {
+		outputs = append(outputs, newPeerOutput(info))
+	}
+	if o.json {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if err := e.Encode(outputs); err != nil {
+			return err
+		}
+	} else {
+		for _, out := range outputs {
+			if err := writePeer(os.Stdout, out, false); err != nil {
+				return err
+			}
+		}
+	}
+	if len(outputs) == 0 {
+		return errors.New("No peers found")
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery"
+)
+
+// attrFlag collects key=value attributes from repeated flags.
+type attrFlag map[string]string
+
+func (a attrFlag) String() string {
+	var parts []string
+	for k, v := range a {
+		parts = append(parts, k+"="+v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (a attrFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("Attribute %q must be in the form key=value", s)
+	}
+	a[k] = v
+	return nil
+}
+
+// options contains the flags shared by all subcommands.
+type options struct {
+	flags    *flag.FlagSet
+	id       string
+	port     int
+	userData string
+	ping     time.Duration
+	timeout  time.Duration
+	attrs    attrFlag
+	filter   attrFlag
+	local    bool
+	json     bool
+}
+
+// Create the flags shared by all subcommands.
+func newOptions(name string) *options {
+	hostname, _ := os.Hostname()
+	o := &options{
+		flags:  flag.NewFlagSet(name, flag.ExitOnError),
+		attrs:  make(attrFlag),
+		filter: make(attrFlag),
+	}
+	o.flags.StringVar(&o.id, "id", fmt.Sprintf("%s-%d", hostname, os.Getpid()), "unique ID of this peer")
+	o.flags.IntVar(&o.port, "port", 1234, "port used for broadcast and multicast")
+	o.flags.StringVar(&o.userData, "data", "", "user data sent to other peers")
+	o.flags.DurationVar(&o.ping, "ping", time.Second, "time between pings")
+	o.flags.DurationVar(&o.timeout, "timeout", 5*time.Second, "time after which a peer is removed")
+	o.flags.Var(o.attrs, "attr", "attribute sent to other peers as key=value (repeatable)")
+	o.flags.Var(o.filter, "filter", "attribute that peers must have as key=value (repeatable)")
+	o.flags.BoolVar(&o.local, "local", false, "also discover peers on this machine using Unix sockets")
+	o.flags.BoolVar(&o.json, "json", false, "write output as JSON")
+	return o
+}
+
+// Create the configuration for a service from the flags.
+func (o *options) config() sdiscovery.ServiceConfig {
+	return sdiscovery.ServiceConfig{
+		PollInterval:    5 * time.Second,
+		PingInterval:    o.ping,
+		PeerTimeout:     o.timeout,
+		Port:            o.port,
+		ID:              o.id,
+		UserData:        []byte(o.userData),
+		Attributes:      o.attrs,
+		AttributeFilter: o.filter,
+		LocalHost:       o.local,
+	}
+}
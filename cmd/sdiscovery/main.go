@@ -0,0 +1,46 @@
+// Command sdiscovery announces a service and browses for peers on the local
+// network, which is useful for troubleshooting a network segment.
+//
+// Usage:
+//
+//	sdiscovery announce [flags]   announce a service until interrupted
+//	sdiscovery browse [flags]     print peers as they are added and removed
+//	sdiscovery probe [flags]      list peers found within a period and exit
+//	sdiscovery sniff [flags]      print every datagram received and why it would be dropped
+//
+// Run a subcommand with -h for a list of its flags.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Subcommands, indexed by name.
+var commands = map[string]func(args []string) error{
+	"announce": announce,
+	"browse":   browse,
+	"probe":    probe,
+	"sniff":    sniff,
+}
+
+// Print usage information.
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: sdiscovery announce|browse|probe|sniff [flags]")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
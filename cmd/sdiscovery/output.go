@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery"
+)
+
+// peerOutput is the JSON representation of a peer.
+type peerOutput struct {
+	Event      string            `json:"event,omitempty"`
+	Time       *time.Time        `json:"time,omitempty"`
+	ID         string            `json:"id"`
+	UserData   string            `json:"user_data,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Addrs      []string          `json:"addrs,omitempty"`
+}
+
+// Convert a peer to its output representation.
+func newPeerOutput(info sdiscovery.PeerInfo) peerOutput {
+	o := peerOutput{
+		ID:         info.ID,
+		UserData:   string(info.UserData),
+		Attributes: info.Attributes,
+	}
+	for _, addr := range info.Addrs {
+		if addr.Interface != "" {
+			o.Addrs = append(o.Addrs, fmt.Sprintf("%s%%%s", addr.IP, addr.Interface))
+		} else {
+			o.Addrs = append(o.Addrs, addr.IP.String())
+		}
+	}
+	return o
+}
+
+// Format the peer as a single line of text.
+func (o peerOutput) text() string {
+	var b strings.Builder
+	if o.Event != "" {
+		fmt.Fprintf(&b, "%-8s ", o.Event)
+	}
+	b.WriteString(o.ID)
+	if len(o.Addrs) != 0 {
+		fmt.Fprintf(&b, " [%s]", strings.Join(o.Addrs, " "))
+	}
+	keys := make([]string, 0, len(o.Attributes))
+	for k := range o.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, o.Attributes[k])
+	}
+	if o.UserData != "" {
+		fmt.Fprintf(&b, " data=%q", o.UserData)
+	}
+	return b.String()
+}
+
+// Write the peer as text or a line of JSON.
+func writePeer(w io.Writer, o peerOutput, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(o)
+	}
+	_, err := fmt.Fprintln(w, o.text())
+	return err
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/nathan-osman/go-sdiscovery"
+	"github.com/nathan-osman/go-sdiscovery/comm"
+	"github.com/nathan-osman/go-sdiscovery/peer"
+)
+
+var testPeer = sdiscovery.PeerInfo{
+	ID:         "a",
+	UserData:   []byte("x"),
+	Attributes: map[string]string{"role": "db", "dc": "1"},
+	Addrs: []peer.AddrInfo{
+		{IP: net.ParseIP("fe80::1"), Interface: "eth0"},
+		{IP: net.IPv4(192, 168, 1, 1)},
+	},
+}
+
+// Ensure that peers are formatted as a line of text.
+func Test_peerOutput_text(t *testing.T) {
+	o := newPeerOutput(testPeer)
+	o.Event = "added"
+	if s := o.text(); s != `added    a [fe80::1%eth0 192.168.1.1] dc=1 role=db data="x"` {
+		t.Fatal(s)
+	}
+}
+
+// Ensure that peers are formatted as JSON.
+func Test_writePeer_JSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := writePeer(&b, newPeerOutput(testPeer), true); err != nil {
+		t.Fatal(err)
+	}
+	var o peerOutput
+	if err := json.Unmarshal(b.Bytes(), &o); err != nil {
+		t.Fatal(err)
+	}
+	if o.ID != "a" || o.Attributes["role"] != "db" || len(o.Addrs) != 2 {
+		t.Fatal("Incorrect JSON output")
+	}
+}
+
+// Ensure that attributes are parsed from flags.
+func Test_attrFlag_Set(t *testing.T) {
+	a := make(attrFlag)
+	if err := a.Set("role=db=1"); err != nil || a["role"] != "db=1" {
+		t.Fatal("Attribute was not parsed")
+	}
+	if a.Set("role") == nil {
+		t.Fatal("Expected error for missing value")
+	}
+}
+
+// Ensure that the reason a datagram would be dropped is determined.
+func Test_newSniffOutput(t *testing.T) {
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 1), Port: 1234}
+	for _, v := range []struct {
+		d       *comm.Datagram
+		dropped string
+	}{
+		{&comm.Datagram{Addr: addr, Packet: &comm.Packet{ID: "a", Attributes: map[string]string{"role": "db"}}}, ""},
+		{&comm.Datagram{Addr: addr, Packet: &comm.Packet{ID: "self"}}, dropSelf},
+		{&comm.Datagram{Addr: addr, Packet: &comm.Packet{ID: "a", Attributes: map[string]string{"role": "web"}}}, dropFilter},
+		{&comm.Datagram{Addr: addr, Dropped: comm.DropDecode, Err: errors.New("bad")}, comm.DropDecode},
+	} {
+		o := newSniffOutput(v.d, "self", map[string]string{"role": "db"})
+		if o.Dropped != v.dropped {
+			t.Fatalf("%q != %q", o.Dropped, v.dropped)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/comm"
+	"github.com/nathan-osman/go-sdiscovery/comm/capture"
+)
+
+// Reasons that a service would drop a valid packet.
+const (
+	dropSelf   = "self"
+	dropFilter = "filter"
+)
+
+// sniffOutput is the representation of a single datagram.
+type sniffOutput struct {
+	Time       time.Time         `json:"time"`
+	Interface  string            `json:"interface"`
+	Type       string            `json:"type"`
+	Addr       string            `json:"addr"`
+	Size       int               `json:"size"`
+	Valid      bool              `json:"valid"`
+	ID         string            `json:"id,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Dropped    string            `json:"dropped,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// Describe the datagram, determining whether a service with the specified ID
+// and attribute filter would drop it.
+func newSniffOutput(d *comm.Datagram, id string, filter map[string]string) sniffOutput {
+	o := sniffOutput{
+		Time:      d.Time,
+		Interface: d.Interface,
+		Type:      d.Type,
+		Addr:      d.Addr.String(),
+		Size:      len(d.Data),
+		Valid:     d.Packet != nil,
+		Dropped:   d.Dropped,
+	}
+	if d.Err != nil {
+		o.Error = d.Err.Error()
+	}
+	if d.Packet != nil {
+		o.ID = d.Packet.ID
+		o.Attributes = d.Packet.Attributes
+		switch {
+		case d.Packet.ID == id:
+			o.Dropped = dropSelf
+		case !matchFilter(d.Packet.Attributes, filter):
+			o.Dropped = dropFilter
+		}
+	}
+	return o
+}
+
+// Determine if the attributes contain every key/value pair in the filter.
+func matchFilter(attrs, filter map[string]string) bool {
+	for k, v := range filter {
+		if attrs[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Format the datagram as a single line of text.
+func (o sniffOutput) text() string {
+	result := "ok"
+	if o.Dropped != "" {
+		result = "dropped (" + o.Dropped + ")"
+	}
+	s := fmt.Sprintf("%s %s/%s %s %d bytes %s", o.Time.Format("15:04:05.000"), o.Interface, o.Type, o.Addr, o.Size, result)
+	if o.ID != "" {
+		s += " id=" + o.ID
+	}
+	if o.Error != "" {
+		s += " error=" + o.Error
+	}
+	return s
+}
+
+// Write the datagram as text or a line of JSON.
+func writeSniff(w io.Writer, o sniffOutput, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(o)
+	}
+	_, err := fmt.Fprintln(w, o.text())
+	return err
+}
+
+// Print every datagram received until interrupted without sending anything.
+func sniff(args []string) error {
+	var (
+		flags  = flag.NewFlagSet("sniff", flag.ExitOnError)
+		port   = flags.Int("port", 1234, "port used for broadcast and multicast")
+		id     = flags.String("id", "", "ID of the local service, whose packets would be dropped")
+		asJSON = flags.Bool("json", false, "write output as JSON")
+		write  = flags.String("write", "", "also record datagrams to a pcapng file")
+		filter = make(attrFlag)
+	)
+	flags.Var(filter, "filter", "attribute that peers must have as key=value (repeatable)")
+	flags.Parse(args)
+
+	// Create the capture file if requested.
+	var (
+		f *os.File
+		w *capture.Writer
+	)
+	if *write != "" {
+		var err error
+		if f, err = os.Create(*write); err != nil {
+			return err
+		}
+		if w, err = capture.NewWriter(f); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	// Receive datagrams from the communicator, dropping them if output
+	// falls behind rather than blocking the sockets. Every datagram is
+	// recorded, regardless.
+	datagrams := make(chan *comm.Datagram, 100)
+	c := comm.NewCommunicatorWithConfig(comm.CommunicatorConfig{
+		PollInterval: 5 * time.Second,
+		Port:         *port,
+		Sniff: func(d *comm.Datagram) {
+			if w != nil {
+				w.Sniff(d)
+			}
+			select {
+			case datagrams <- d:
+			default:
+			}
+		},
+	})
+
+	// Packets and events are not needed but must be drained. The packet
+	// channel is closed once every connection has stopped, after which no
+	// more datagrams are sniffed.
+	doneChan := make(chan struct{})
+	go func() {
+		for range c.Receive() {
+		}
+		close(doneChan)
+	}()
+	go func() {
+		for range c.Events() {
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening on port %d\n", *port)
+	signalChan := interrupted()
+	var err error
+loop:
+	for {
+		select {
+		case d := <-datagrams:
+			if err = writeSniff(os.Stdout, newSniffOutput(d, *id, filter), *asJSON); err != nil {
+				break loop
+			}
+		case <-signalChan:
+			break loop
+		}
+	}
+
+	// Stop the communicator and wait for the connections to finish before
+	// closing the capture file.
+	c.Close()
+	<-doneChan
+	if f != nil {
+		if wErr := w.Err(); err == nil {
+			err = wErr
+		}
+		if cErr := f.Close(); err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/comm"
+)
+
+var (
+	testTime  = time.Unix(1700000000, 123456000)
+	testAddr4 = &net.UDPAddr{IP: net.IPv4(192, 168, 1, 1).To4(), Port: 1234}
+	testDest4 = &net.UDPAddr{IP: net.IPv4(192, 168, 1, 255).To4(), Port: 1234}
+	testAddr6 = &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 1234}
+	testDest6 = &net.UDPAddr{IP: net.ParseIP("ff02::1"), Port: 1234}
+)
+
+// Ensure that records survive being written and read again.
+func Test_Writer_Reader(t *testing.T) {
+
+	records := []*Record{
+		{Time: testTime, Interface: "eth0", Type: "broadcast", Addr: testAddr4, Dest: testDest4, Data: []byte(`{"id":"a"}`)},
+		{Time: testTime.Add(time.Second), Interface: "eth0", Type: "multicast", Addr: testAddr6, Dest: testDest6, Data: []byte(`{"id":"bb"}`)},
+		{Time: testTime.Add(2 * time.Second), Interface: "eth0", Type: "broadcast", Addr: testAddr4, Dest: testDest4, Data: []byte(`{`)},
+	}
+
+	var b bytes.Buffer
+	w, err := NewWriter(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := w.WriteRecord(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewReader(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(records) {
+		t.Fatalf("%d records read", len(read))
+	}
+	for i, rec := range read {
+		exp := records[i]
+		if !rec.Time.Equal(exp.Time) || rec.Interface != exp.Interface || rec.Type != exp.Type {
+			t.Fatalf("%d: metadata does not match", i)
+		}
+		if !rec.Addr.IP.Equal(exp.Addr.IP) || rec.Addr.Port != exp.Addr.Port || !rec.Dest.IP.Equal(exp.Dest.IP) {
+			t.Fatalf("%d: addresses do not match", i)
+		}
+		if !bytes.Equal(rec.Data, exp.Data) {
+			t.Fatalf("%d: data does not match", i)
+		}
+	}
+}
+
+// Ensure that the IPv6 UDP checksum is valid.
+func Test_encodeIP_checksum(t *testing.T) {
+	b := encodeIP(testAddr6, testDest6, []byte("abc"))
+	sum := sum16(0, b[8:40])
+	sum += uint32(len(b)-ipv6HeaderSize) + protoUDP
+	if fold(sum16(sum, b[ipv6HeaderSize:])) != 0 {
+		t.Fatal("Invalid UDP checksum")
+	}
+	if fold(sum16(0, encodeIP(testAddr4, testDest4, nil)[:ipv4HeaderSize])) != 0 {
+		t.Fatal("Invalid IPv4 header checksum")
+	}
+}
+
+// Ensure that classic pcap files with Ethernet headers can be read.
+func Test_Reader_pcap(t *testing.T) {
+
+	// Create an Ethernet frame containing the datagram.
+	frame := append(make([]byte, 12), 0x08, 0x00)
+	frame = append(frame, encodeIP(testAddr4, testDest4, []byte(`{"id":"a"}`))...)
+
+	var b bytes.Buffer
+	header := make([]byte, 24)
+	binary.BigEndian.PutUint32(header, pcapMagicMicro)
+	binary.BigEndian.PutUint32(header[20:], linkTypeEthernet)
+	b.Write(header)
+	rec := make([]byte, 16)
+	binary.BigEndian.PutUint32(rec, uint32(testTime.Unix()))
+	binary.BigEndian.PutUint32(rec[4:], 123456)
+	binary.BigEndian.PutUint32(rec[8:], uint32(len(frame)))
+	binary.BigEndian.PutUint32(rec[12:], uint32(len(frame)))
+	b.Write(rec)
+	b.Write(frame)
+
+	r, err := NewReader(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || !records[0].Time.Equal(testTime) ||
+		!records[0].Addr.IP.Equal(testAddr4.IP) || string(records[0].Data) != `{"id":"a"}` {
+		t.Fatal("Record does not match")
+	}
+}
+
+// Ensure that datagrams can be recorded using the Sniff method.
+func Test_Writer_Sniff(t *testing.T) {
+	var b bytes.Buffer
+	w, _ := NewWriter(&b)
+	w.Sniff(&comm.Datagram{Time: testTime, Interface: "eth0", Addr: testAddr4, Data: []byte("x")})
+	if w.Err() != nil {
+		t.Fatal(w.Err())
+	}
+	r, _ := NewReader(&b)
+	if records, err := r.ReadAll(); err != nil || len(records) != 1 {
+		t.Fatal("Datagram was not recorded")
+	}
+}
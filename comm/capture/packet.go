@@ -0,0 +1,140 @@
+package capture
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+const (
+	protoUDP       = 17
+	ipv4HeaderSize = 20
+	ipv6HeaderSize = 40
+	udpHeaderSize  = 8
+)
+
+var errNotUDP = errors.New("Not a UDP datagram")
+
+// Fold a 32-bit sum into a 16-bit ones' complement checksum.
+func fold(sum uint32) uint16 {
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// Add the 16-bit words in b to the sum.
+func sum16(sum uint32, b []byte) uint32 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	return sum
+}
+
+// Build a raw IPv4 or IPv6 packet containing a UDP datagram so that captures
+// can be opened with standard tools. The family is determined by the source
+// address. If dest is nil, an unspecified address is used.
+func encodeIP(src, dest *net.UDPAddr, payload []byte) []byte {
+
+	// Create the UDP header. The checksum is filled in below.
+	udpLen := udpHeaderSize + len(payload)
+	udp := make([]byte, udpLen)
+	binary.BigEndian.PutUint16(udp[0:], uint16(src.Port))
+	if dest != nil {
+		binary.BigEndian.PutUint16(udp[2:], uint16(dest.Port))
+	}
+	binary.BigEndian.PutUint16(udp[4:], uint16(udpLen))
+	copy(udp[udpHeaderSize:], payload)
+
+	if src4 := src.IP.To4(); src4 != nil {
+		dest4 := net.IPv4zero.To4()
+		if dest != nil && dest.IP.To4() != nil {
+			dest4 = dest.IP.To4()
+		}
+		ip := make([]byte, ipv4HeaderSize)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(ipv4HeaderSize+udpLen))
+		ip[8] = 1
+		ip[9] = protoUDP
+		copy(ip[12:], src4)
+		copy(ip[16:], dest4)
+		binary.BigEndian.PutUint16(ip[10:], fold(sum16(0, ip)))
+
+		// The UDP checksum is optional for IPv4 and left as zero.
+		return append(ip, udp...)
+	}
+
+	dest16 := net.IPv6unspecified
+	if dest != nil && dest.IP.To4() == nil && dest.IP.To16() != nil {
+		dest16 = dest.IP.To16()
+	}
+	ip := make([]byte, ipv6HeaderSize)
+	ip[0] = 0x60
+	binary.BigEndian.PutUint16(ip[4:], uint16(udpLen))
+	ip[6] = protoUDP
+	ip[7] = 1
+	copy(ip[8:], src.IP.To16())
+	copy(ip[24:], dest16)
+
+	// The UDP checksum is mandatory for IPv6 and covers a pseudo-header.
+	sum := sum16(0, ip[8:40])
+	sum += uint32(udpLen) + protoUDP
+	checksum := fold(sum16(sum, udp))
+	if checksum == 0 {
+		checksum = 0xffff
+	}
+	binary.BigEndian.PutUint16(udp[6:], checksum)
+
+	return append(ip, udp...)
+}
+
+// Extract the addresses and payload from a raw IPv4 or IPv6 packet containing
+// a UDP datagram.
+func decodeIP(b []byte) (src, dest *net.UDPAddr, payload []byte, err error) {
+
+	if len(b) == 0 {
+		return nil, nil, nil, errNotUDP
+	}
+
+	// Find the UDP header and addresses for the IP version.
+	var srcIP, destIP net.IP
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < ipv4HeaderSize {
+			return nil, nil, nil, errNotUDP
+		}
+		ihl := int(b[0]&0x0f) * 4
+		if b[9] != protoUDP || ihl < ipv4HeaderSize || len(b) < ihl {
+			return nil, nil, nil, errNotUDP
+		}
+		srcIP = net.IP(append([]byte{}, b[12:16]...))
+		destIP = net.IP(append([]byte{}, b[16:20]...))
+		b = b[ihl:]
+	case 6:
+		if len(b) < ipv6HeaderSize || b[6] != protoUDP {
+			return nil, nil, nil, errNotUDP
+		}
+		srcIP = net.IP(append([]byte{}, b[8:24]...))
+		destIP = net.IP(append([]byte{}, b[24:40]...))
+		b = b[ipv6HeaderSize:]
+	default:
+		return nil, nil, nil, errNotUDP
+	}
+
+	// Extract the ports and payload.
+	if len(b) < udpHeaderSize {
+		return nil, nil, nil, errNotUDP
+	}
+	udpLen := int(binary.BigEndian.Uint16(b[4:]))
+	if udpLen < udpHeaderSize || udpLen > len(b) {
+		udpLen = len(b)
+	}
+	src = &net.UDPAddr{IP: srcIP, Port: int(binary.BigEndian.Uint16(b[0:]))}
+	dest = &net.UDPAddr{IP: destIP, Port: int(binary.BigEndian.Uint16(b[2:]))}
+	payload = append([]byte{}, b[udpHeaderSize:udpLen]...)
+
+	return src, dest, payload, nil
+}
@@ -0,0 +1,331 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"time"
+)
+
+const (
+	pcapMagicMicro = 0xa1b2c3d4
+	pcapMagicNano  = 0xa1b23c4d
+	maxBlockSize   = 16 * 1024 * 1024
+)
+
+var errInvalidFormat = errors.New("Not a pcap or pcapng file")
+
+// Description of an interface in a pcapng capture.
+type captureInterface struct {
+	linkType uint16
+	name     string
+	desc     string
+	tsPerSec uint64
+}
+
+// Reader reads records from a capture in the pcapng format or the classic
+// pcap format written by tcpdump. Only UDP datagrams over IPv4 or IPv6 are
+// returned; other packets are skipped. Captures using raw IP, Ethernet or
+// Linux cooked link types are supported.
+type Reader struct {
+	r          *bufio.Reader
+	order      binary.ByteOrder
+	pcapng     bool
+	interfaces []captureInterface
+}
+
+// Create a new reader, determining the format from the header.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReader(r)}
+	header, err := reader.r.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+
+	// pcapng files begin with a section header, which is read with the
+	// rest of the blocks. Classic pcap files begin with a magic number in
+	// either byte order that also indicates the timestamp resolution.
+	if binary.LittleEndian.Uint32(header) == blockSectionHeader {
+		reader.pcapng = true
+		return reader, nil
+	}
+	if err := reader.readPcapHeader(); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Read the global header of a classic pcap file.
+func (r *Reader) readPcapHeader() error {
+	b := make([]byte, 24)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return err
+	}
+	var perSec uint64
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(b) {
+		case pcapMagicMicro:
+			r.order, perSec = order, 1e6
+		case pcapMagicNano:
+			r.order, perSec = order, 1e9
+		}
+	}
+	if r.order == nil {
+		return errInvalidFormat
+	}
+	r.interfaces = []captureInterface{{
+		linkType: uint16(r.order.Uint32(b[20:])),
+		tsPerSec: perSec,
+	}}
+	return nil
+}
+
+// Read the next record from the capture, returning io.EOF at the end.
+func (r *Reader) Read() (*Record, error) {
+	for {
+		var (
+			rec *Record
+			err error
+		)
+		if r.pcapng {
+			rec, err = r.readBlock()
+		} else {
+			rec, err = r.readPcapRecord()
+		}
+		if err != nil || rec != nil {
+			return rec, err
+		}
+	}
+}
+
+// Read all remaining records from the capture.
+func (r *Reader) ReadAll() ([]*Record, error) {
+	var records []*Record
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+}
+
+// Read all records from the capture file at the specified path.
+func ReadFile(path string) ([]*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return r.ReadAll()
+}
+
+// Convert a timestamp with the specified number of units per second to a
+// time. The fraction is calculated with 128-bit arithmetic to avoid overflow.
+func toTime(ts, perSec uint64) time.Time {
+	hi, lo := bits.Mul64(ts%perSec, 1e9)
+	nsec, _ := bits.Div64(hi, lo, perSec)
+	return time.Unix(int64(ts/perSec), int64(nsec))
+}
+
+// Read a single record from a classic pcap file. Nil is returned for packets
+// that are not UDP datagrams.
+func (r *Reader) readPcapRecord() (*Record, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return nil, err
+	}
+	capLen := r.order.Uint32(b[8:])
+	if capLen > maxBlockSize {
+		return nil, errInvalidFormat
+	}
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, unexpected(err)
+	}
+	ifi := &r.interfaces[0]
+	sec, sub := uint64(r.order.Uint32(b)), uint64(r.order.Uint32(b[4:]))
+	t := time.Unix(int64(sec), int64(sub*1e9/ifi.tsPerSec))
+	return newRecord(ifi, t, data), nil
+}
+
+// Read a single pcapng block. Nil is returned for blocks that do not contain
+// UDP datagrams.
+func (r *Reader) readBlock() (*Record, error) {
+
+	// Read the block header. The byte order is only known once the section
+	// header has been read, so the length of a section header is decoded
+	// after reading its magic number.
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(header) == blockSectionHeader {
+		magic, err := r.r.Peek(4)
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		switch {
+		case binary.LittleEndian.Uint32(magic) == byteOrderMagic:
+			r.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(magic) == byteOrderMagic:
+			r.order = binary.BigEndian
+		default:
+			return nil, errInvalidFormat
+		}
+		r.interfaces = nil
+	}
+	if r.order == nil {
+		return nil, errInvalidFormat
+	}
+	blockType, length := r.order.Uint32(header), r.order.Uint32(header[4:])
+	if length < 12 || length%4 != 0 || length > maxBlockSize {
+		return nil, errInvalidFormat
+	}
+	body := make([]byte, length-8)
+	if _, err := io.ReadFull(r.r, body); err != nil {
+		return nil, unexpected(err)
+	}
+	body = body[:len(body)-4]
+
+	switch blockType {
+	case blockInterfaceDescription:
+		return nil, r.readInterface(body)
+	case blockEnhancedPacket:
+		if len(body) < 20 {
+			return nil, errInvalidFormat
+		}
+		id := r.order.Uint32(body)
+		if int(id) >= len(r.interfaces) {
+			return nil, fmt.Errorf("Packet refers to unknown interface %d", id)
+		}
+		ifi := &r.interfaces[id]
+		ts := uint64(r.order.Uint32(body[4:]))<<32 | uint64(r.order.Uint32(body[8:]))
+		capLen := r.order.Uint32(body[12:])
+		if int(capLen) > len(body)-20 {
+			return nil, errInvalidFormat
+		}
+		return newRecord(ifi, toTime(ts, ifi.tsPerSec), body[20:20+capLen]), nil
+	}
+
+	// Skip all other blocks.
+	return nil, nil
+}
+
+// Determine the number of timestamp units per second from the resolution
+// option, which is a negative power of ten or of two if the high bit is set.
+// Resolutions finer than a nanosecond are not supported and fall back to the
+// default of microseconds.
+func resolution(v byte) uint64 {
+	if v&0x80 == 0 {
+		if v > 9 {
+			return 1e6
+		}
+		perSec := uint64(1)
+		for i := byte(0); i < v; i++ {
+			perSec *= 10
+		}
+		return perSec
+	}
+	if v&0x7f > 30 {
+		return 1e6
+	}
+	return 1 << (v & 0x7f)
+}
+
+// Parse an interface description block.
+func (r *Reader) readInterface(body []byte) error {
+	if len(body) < 8 {
+		return errInvalidFormat
+	}
+	ifi := captureInterface{
+		linkType: r.order.Uint16(body),
+		tsPerSec: 1e6,
+	}
+	for opts := body[8:]; len(opts) >= 4; {
+		code, length := r.order.Uint16(opts), int(r.order.Uint16(opts[2:]))
+		if code == optEndOfOpt || 4+length > len(opts) {
+			break
+		}
+		value := opts[4 : 4+length]
+		switch code {
+		case optIfName:
+			ifi.name = string(value)
+		case optIfDescription:
+			ifi.desc = string(value)
+		case optIfTSResol:
+			if length == 1 {
+				ifi.tsPerSec = resolution(value[0])
+			}
+		}
+		opts = opts[4+pad(length):]
+	}
+	r.interfaces = append(r.interfaces, ifi)
+	return nil
+}
+
+// Create a record from a captured packet, returning nil if it is not a UDP
+// datagram.
+func newRecord(ifi *captureInterface, t time.Time, data []byte) *Record {
+	ip, ok := stripLinkHeader(ifi.linkType, data)
+	if !ok {
+		return nil
+	}
+	src, dest, payload, err := decodeIP(ip)
+	if err != nil {
+		return nil
+	}
+	return &Record{
+		Time:      t,
+		Interface: ifi.name,
+		Type:      ifi.desc,
+		Addr:      src,
+		Dest:      dest,
+		Data:      payload,
+	}
+}
+
+// Remove the link-layer header, if any, from a captured packet.
+func stripLinkHeader(linkType uint16, data []byte) ([]byte, bool) {
+	switch linkType {
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		return data, true
+	case linkTypeEthernet:
+		if len(data) < 14 {
+			return nil, false
+		}
+		etherType, offset := binary.BigEndian.Uint16(data[12:]), 14
+		if etherType == 0x8100 && len(data) >= 18 {
+			etherType, offset = binary.BigEndian.Uint16(data[16:]), 18
+		}
+		if etherType != 0x0800 && etherType != 0x86dd {
+			return nil, false
+		}
+		return data[offset:], true
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil, false
+		}
+		return data[16:], true
+	}
+	return nil, false
+}
+
+// Treat the end of the file in the middle of a block as an error.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,30 @@
+package capture
+
+import (
+	"net"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/comm"
+)
+
+// Record is a single datagram stored in a capture.
+type Record struct {
+	Time      time.Time    // when the datagram was received
+	Interface string       // name of the interface that received it
+	Type      string       // type of connection that received it
+	Addr      *net.UDPAddr // address of the sender
+	Dest      *net.UDPAddr // address the datagram was sent to
+	Data      []byte       // UDP payload
+}
+
+// Create a record from a datagram received by a connection.
+func NewRecord(d *comm.Datagram) *Record {
+	return &Record{
+		Time:      d.Time,
+		Interface: d.Interface,
+		Type:      d.Type,
+		Addr:      d.Addr,
+		Dest:      d.Dest,
+		Data:      d.Data,
+	}
+}
@@ -0,0 +1,130 @@
+package capture
+
+import (
+	"sync"
+
+	"github.com/nathan-osman/go-sdiscovery/comm"
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// ReplayInterface is the interface name used for records that do not have
+// one, such as those read from classic pcap files.
+const ReplayInterface = "replay"
+
+// Replay is a Transport that feeds recorded datagrams to a service. Each
+// record is delivered once the clock has advanced past its offset from the
+// first record, so a util.FakeClock shared with the service allows a capture
+// to be replayed in simulated time. Datagrams that are not valid packets are
+// skipped, just as they are by the Communicator. Packets sent by the service
+// are discarded.
+type Replay struct {
+	records    []*Record
+	clock      util.Clock
+	packetChan chan *comm.Packet
+	eventChan  chan comm.InterfaceEvent
+	stopChan   chan interface{}
+	doneChan   chan struct{}
+	closeOnce  sync.Once
+}
+
+// Create a new transport that replays the records, which must be sorted by
+// time. If clock is nil, util.SystemClock is used and the records are
+// replayed in real time.
+func NewReplay(records []*Record, clock util.Clock) *Replay {
+	if clock == nil {
+		clock = util.SystemClock
+	}
+	r := &Replay{
+		records:    records,
+		clock:      clock,
+		packetChan: make(chan *comm.Packet),
+		eventChan:  make(chan comm.InterfaceEvent),
+		stopChan:   make(chan interface{}),
+		doneChan:   make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Determine the name of the interface for a record.
+func interfaceName(rec *Record) string {
+	if rec.Interface == "" {
+		return ReplayInterface
+	}
+	return rec.Interface
+}
+
+// Deliver each of the records at the appropriate time.
+func (r *Replay) run() {
+
+	defer close(r.packetChan)
+	defer close(r.eventChan)
+
+	// Announce each of the interfaces in the order they first appear.
+	seen := make(map[string]bool)
+	for _, rec := range r.records {
+		name := interfaceName(rec)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		select {
+		case r.eventChan <- comm.InterfaceEvent{Name: name, Added: true}:
+		case <-r.stopChan:
+			return
+		}
+	}
+
+	// Deliver the records relative to the current time.
+	start := r.clock.Now()
+	for _, rec := range r.records {
+		if delay := start.Add(rec.Time.Sub(r.records[0].Time)).Sub(r.clock.Now()); delay > 0 {
+			select {
+			case <-r.clock.After(delay):
+			case <-r.stopChan:
+				return
+			}
+		}
+		pkt, err := comm.NewPacketFromJSON(rec.Addr.IP, rec.Data)
+		if err != nil {
+			continue
+		}
+		pkt.Interface = interfaceName(rec)
+		pkt.Type = rec.Type
+		select {
+		case r.packetChan <- pkt:
+		case <-r.stopChan:
+			return
+		}
+	}
+
+	// Indicate that replay has finished but keep the channels open until
+	// the transport is closed, since closing them stops the service.
+	close(r.doneChan)
+	<-r.stopChan
+}
+
+// Discard the packet.
+func (r *Replay) Send(pkt *comm.Packet) {}
+
+// Obtain the channel on which recorded packets are delivered.
+func (r *Replay) Receive() <-chan *comm.Packet {
+	return r.packetChan
+}
+
+// Obtain the channel on which the recorded interfaces are announced.
+func (r *Replay) Events() <-chan comm.InterfaceEvent {
+	return r.eventChan
+}
+
+// Obtain a channel that is closed once every record has been delivered.
+func (r *Replay) Done() <-chan struct{} {
+	return r.doneChan
+}
+
+// Stop replaying records.
+func (r *Replay) Close() {
+	r.closeOnce.Do(func() {
+		close(r.stopChan)
+	})
+}
@@ -0,0 +1,61 @@
+package capture
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery"
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Ensure that a capture drives a service in simulated time.
+func Test_Replay(t *testing.T) {
+
+	records := []*Record{
+		{Time: testTime, Interface: "eth0", Addr: testAddr4, Data: []byte(`{"id":"a"}`)},
+		{Time: testTime.Add(2 * time.Second), Interface: "eth0", Addr: testAddr4, Data: []byte(`{`)},
+		{Time: testTime.Add(10 * time.Second), Addr: testAddr4, Data: []byte(`{"id":"b"}`)},
+	}
+	c := util.NewFakeClock(time.Unix(0, 0))
+	r := NewReplay(records, c)
+	s := sdiscovery.New(sdiscovery.ServiceConfig{
+		PingInterval: time.Second,
+		PeerTimeout:  4 * time.Second,
+		ID:           "local",
+		Transport:    r,
+		Clock:        c,
+	})
+	defer s.Stop()
+
+	// The first record is delivered immediately.
+	if id := <-s.PeerAdded; id != "a" {
+		t.Fatal("Expected peer a")
+	}
+
+	// Wait for the tickers and the replay timer before advancing past each
+	// of the remaining records. The invalid record is skipped, peer a times
+	// out and peer b is found.
+	c.BlockUntil(3)
+	c.Advance(2 * time.Second)
+	c.BlockUntil(3)
+	c.Advance(8 * time.Second)
+	var added, removed string
+	timeout := time.After(time.Second)
+	for added == "" || removed == "" {
+		select {
+		case added = <-s.PeerAdded:
+		case removed = <-s.PeerRemoved:
+		case <-timeout:
+			t.Fatal("Timed out waiting for peers")
+		}
+	}
+	if added != "b" || removed != "a" {
+		t.Fatal("Incorrect peers added or removed")
+	}
+	<-r.Done()
+
+	// Record b has no interface and uses the default name.
+	if names := s.Interfaces(); len(names) != 2 || names[1] != ReplayInterface {
+		t.Fatal("Interfaces were not announced")
+	}
+}
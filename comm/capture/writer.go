@@ -0,0 +1,163 @@
+package capture
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/nathan-osman/go-sdiscovery/comm"
+)
+
+const (
+	blockSectionHeader        = 0x0a0d0d0a
+	blockInterfaceDescription = 0x00000001
+	blockEnhancedPacket       = 0x00000006
+	byteOrderMagic            = 0x1a2b3c4d
+
+	optEndOfOpt      = 0
+	optIfName        = 2
+	optIfDescription = 3
+	optIfTSResol     = 9
+
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+)
+
+// Each combination of interface name and connection type is stored as a
+// separate interface in the capture.
+type interfaceKey struct {
+	name  string
+	pType string
+}
+
+// Writer stores datagrams in the pcapng format, which can be opened with
+// tools such as Wireshark and read with Reader. The interface name is stored
+// in each interface description and the connection type in its description.
+// Datagrams are stored as raw IP packets with synthesized headers.
+type Writer struct {
+	mutex      sync.Mutex
+	w          io.Writer
+	interfaces map[interfaceKey]uint32
+	err        error
+}
+
+// Round the length up to a multiple of four bytes.
+func pad(n int) int {
+	return (n + 3) &^ 3
+}
+
+// Write a block with the specified type and body, which must already be
+// padded.
+func writeBlock(w io.Writer, blockType uint32, body []byte) error {
+	length := uint32(12 + len(body))
+	b := make([]byte, 0, length)
+	b = binary.LittleEndian.AppendUint32(b, blockType)
+	b = binary.LittleEndian.AppendUint32(b, length)
+	b = append(b, body...)
+	b = binary.LittleEndian.AppendUint32(b, length)
+	_, err := w.Write(b)
+	return err
+}
+
+// Append an option with a string value.
+func appendOption(b []byte, code uint16, value string) []byte {
+	b = binary.LittleEndian.AppendUint16(b, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	return append(b, make([]byte, pad(len(value))-len(value))...)
+}
+
+// Create a new writer, writing the section header immediately.
+func NewWriter(w io.Writer) (*Writer, error) {
+	body := make([]byte, 0, 16)
+	body = binary.LittleEndian.AppendUint32(body, byteOrderMagic)
+	body = binary.LittleEndian.AppendUint16(body, 1)
+	body = binary.LittleEndian.AppendUint16(body, 0)
+	body = binary.LittleEndian.AppendUint64(body, math.MaxUint64)
+	if err := writeBlock(w, blockSectionHeader, body); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:          w,
+		interfaces: make(map[interfaceKey]uint32),
+	}, nil
+}
+
+// Obtain the ID of the interface, writing its description if it is new. The
+// writer must be locked.
+func (w *Writer) interfaceID(name, pType string) (uint32, error) {
+	key := interfaceKey{name: name, pType: pType}
+	if id, ok := w.interfaces[key]; ok {
+		return id, nil
+	}
+	body := make([]byte, 0, 32)
+	body = binary.LittleEndian.AppendUint16(body, linkTypeRaw)
+	body = binary.LittleEndian.AppendUint16(body, 0)
+	body = binary.LittleEndian.AppendUint32(body, 0)
+	if name != "" {
+		body = appendOption(body, optIfName, name)
+	}
+	if pType != "" {
+		body = appendOption(body, optIfDescription, pType)
+	}
+	body = appendOption(body, optEndOfOpt, "")
+	if err := writeBlock(w.w, blockInterfaceDescription, body); err != nil {
+		return 0, err
+	}
+	id := uint32(len(w.interfaces))
+	w.interfaces[key] = id
+	return id, nil
+}
+
+// Write a record to the capture.
+func (w *Writer) WriteRecord(r *Record) error {
+
+	// Obtain exclusive access to the writer.
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	id, err := w.interfaceID(r.Interface, r.Type)
+	if err != nil {
+		return err
+	}
+
+	// Timestamps use the default resolution of microseconds.
+	data := encodeIP(r.Addr, r.Dest, r.Data)
+	ts := uint64(r.Time.UnixMicro())
+	body := make([]byte, 0, 20+pad(len(data)))
+	body = binary.LittleEndian.AppendUint32(body, id)
+	body = binary.LittleEndian.AppendUint32(body, uint32(ts>>32))
+	body = binary.LittleEndian.AppendUint32(body, uint32(ts))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(data)))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(data)))
+	body = append(body, data...)
+	body = append(body, make([]byte, pad(len(data))-len(data))...)
+
+	return writeBlock(w.w, blockEnhancedPacket, body)
+}
+
+// Record a datagram received by a connection. This method can be used as the
+// Sniff function of a Communicator. Since it cannot return an error, the
+// first error encountered is available from Err() and later datagrams are
+// discarded.
+func (w *Writer) Sniff(d *comm.Datagram) {
+	if w.Err() != nil {
+		return
+	}
+	if err := w.WriteRecord(NewRecord(d)); err != nil {
+		w.mutex.Lock()
+		w.err = err
+		w.mutex.Unlock()
+	}
+}
+
+// Obtain the first error encountered by Sniff().
+func (w *Writer) Err() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.err
+}
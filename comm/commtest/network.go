@@ -0,0 +1,185 @@
+// Package commtest provides a simulated network for testing services without
+// sending any packets on real network interfaces.
+//
+// A Network consists of any number of nodes, each of which implements the
+// comm.Transport interface and can be passed to a service. Nodes are attached
+// to named virtual interfaces and packets sent by a node are delivered to all
+// other nodes attached to the same interface, subject to the loss, latency
+// and partitions configured for the network.
+package commtest
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/comm"
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Network simulates a set of network segments shared by nodes.
+type Network struct {
+	mutex      sync.Mutex
+	rand       *rand.Rand
+	clock      util.Clock
+	loss       float64
+	latency    time.Duration
+	nodes      []*Node
+	interfaces []string
+	partitions map[[2]string]bool
+}
+
+// Create a new network. The seed is used for determining which packets are
+// lost so that tests are repeatable.
+func NewNetwork(seed int64) *Network {
+	return &Network{
+		rand:       rand.New(rand.NewSource(seed)),
+		clock:      util.SystemClock,
+		partitions: make(map[[2]string]bool),
+	}
+}
+
+// Set the probability (between 0 and 1) that any given packet is lost.
+func (n *Network) SetLoss(loss float64) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.loss = loss
+}
+
+// Set the clock used for delaying packets. This should be the same clock used
+// by the services on the network.
+func (n *Network) SetClock(clock util.Clock) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.clock = clock
+}
+
+// Set the delay between a packet being sent and being received.
+func (n *Network) SetLatency(latency time.Duration) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.latency = latency
+}
+
+// Create a key for the partition map that is independent of order.
+func partitionKey(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// Prevent packets from being exchanged between the two named nodes.
+func (n *Network) Partition(a, b string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.partitions[partitionKey(a, b)] = true
+}
+
+// Allow packets to be exchanged between the two named nodes again.
+func (n *Network) Heal(a, b string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	delete(n.partitions, partitionKey(a, b))
+}
+
+// Create a new node with the specified name, attached to each of the named
+// interfaces. Names must be unique within the network.
+func (n *Network) NewNode(name string, ifiNames ...string) *Node {
+
+	// Create the node and register it with the network.
+	n.mutex.Lock()
+	node := newNode(n, name, len(n.nodes)+1)
+	n.nodes = append(n.nodes, node)
+	n.mutex.Unlock()
+
+	// Attach the node to each of the interfaces.
+	for _, ifiName := range ifiNames {
+		node.AddInterface(ifiName)
+	}
+
+	return node
+}
+
+// Generate an address for a node on an interface. The interface determines
+// the subnet and the order in which nodes were created determines the host.
+// The network must be locked.
+func (n *Network) addr(node *Node, ifiName string) net.IP {
+	i := 0
+	for ; i < len(n.interfaces); i++ {
+		if n.interfaces[i] == ifiName {
+			break
+		}
+	}
+	if i == len(n.interfaces) {
+		n.interfaces = append(n.interfaces, ifiName)
+	}
+	return net.IPv4(10, byte(i), byte(node.index>>8), byte(node.index))
+}
+
+// Deliver a packet from the node on each of its interfaces.
+func (n *Network) send(from *Node, pkt *comm.Packet) {
+
+	// Encode the packet as it would be sent on a real network.
+	data, err := pkt.ToJSON()
+	if err != nil {
+		panic(fmt.Sprintf("unable to encode packet: %s", err))
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	// Iterate over interfaces and nodes in the order they were created so
+	// that the same seed always drops the same packets.
+	for _, ifiName := range n.interfaces {
+		ip := from.addrs[ifiName]
+		if ip == nil {
+			continue
+		}
+		for _, to := range n.nodes {
+
+			// Skip nodes not on the interface and those that cannot be
+			// reached, including the sender itself.
+			if to == from || to.addrs[ifiName] == nil ||
+				n.partitions[partitionKey(from.name, to.name)] {
+				continue
+			}
+
+			// Determine whether the packet is lost.
+			if n.loss > 0 && n.rand.Float64() < n.loss {
+				continue
+			}
+
+			// Decode a separate copy for each recipient.
+			p, err := comm.NewPacketFromJSON(ip, data)
+			if err != nil {
+				panic(fmt.Sprintf("unable to decode packet: %s", err))
+			}
+			p.Interface = ifiName
+
+			// Deliver the packet immediately or after the latency.
+			if n.latency == 0 {
+				to.deliver(p)
+			} else {
+				go func(to *Node, after <-chan time.Time) {
+					<-after
+					to.deliver(p)
+				}(to, n.clock.After(n.latency))
+			}
+		}
+	}
+}
+
+// Remove the node from the network.
+func (n *Network) remove(node *Node) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for i, other := range n.nodes {
+		if other == node {
+			n.nodes = append(n.nodes[:i], n.nodes[i+1:]...)
+			break
+		}
+	}
+}
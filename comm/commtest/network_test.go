@@ -0,0 +1,84 @@
+package commtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/comm"
+)
+
+// Attempt to receive a packet from the node.
+func receive(n *Node) *comm.Packet {
+	select {
+	case pkt := <-n.Receive():
+		return pkt
+	case <-time.After(100 * time.Millisecond):
+		return nil
+	}
+}
+
+// Ensure that packets are delivered only to nodes sharing an interface.
+func Test_Network_Send(t *testing.T) {
+
+	n := NewNetwork(0)
+	a := n.NewNode("a", "eth0")
+	defer a.Close()
+	b := n.NewNode("b", "eth0")
+	defer b.Close()
+	c := n.NewNode("c", "wlan0")
+	defer c.Close()
+
+	// Ensure the interface event is sent.
+	if e := <-a.Events(); e.Name != "eth0" || !e.Added {
+		t.Fatal("Expected interface to be added")
+	}
+
+	a.Send(&comm.Packet{ID: "a"})
+
+	// Node b should receive the packet with the address of node a.
+	pkt := receive(b)
+	if pkt == nil {
+		t.Fatal("Packet was not delivered")
+	}
+	if pkt.ID != "a" || pkt.Interface != "eth0" || !pkt.IP.Equal(a.Addr("eth0")) {
+		t.Fatal("Packet contents are incorrect")
+	}
+
+	// Node c should not receive the packet.
+	if receive(c) != nil {
+		t.Fatal("Packet delivered to another interface")
+	}
+}
+
+// Ensure that partitions and loss prevent delivery.
+func Test_Network_Partition(t *testing.T) {
+
+	n := NewNetwork(0)
+	a := n.NewNode("a", "eth0")
+	defer a.Close()
+	b := n.NewNode("b", "eth0")
+	defer b.Close()
+
+	// Partitioned nodes should not receive packets.
+	n.Partition("a", "b")
+	a.Send(&comm.Packet{ID: "a"})
+	if receive(b) != nil {
+		t.Fatal("Packet delivered across partition")
+	}
+
+	// Neither should nodes on a network that loses every packet.
+	n.Heal("a", "b")
+	n.SetLoss(1)
+	a.Send(&comm.Packet{ID: "a"})
+	if receive(b) != nil {
+		t.Fatal("Packet delivered despite loss")
+	}
+
+	// Packets should arrive after the latency once the loss is removed.
+	n.SetLoss(0)
+	n.SetLatency(10 * time.Millisecond)
+	a.Send(&comm.Packet{ID: "a"})
+	if receive(b) == nil {
+		t.Fatal("Packet was not delivered")
+	}
+}
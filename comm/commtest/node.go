@@ -0,0 +1,165 @@
+package commtest
+
+import (
+	"net"
+	"sync"
+
+	"github.com/nathan-osman/go-sdiscovery/comm"
+)
+
+// Node is a single host on a simulated network. It implements comm.Transport.
+type Node struct {
+	network    *Network
+	name       string
+	index      int
+	addrs      map[string]net.IP
+	mutex      sync.Mutex
+	packets    []*comm.Packet
+	events     []comm.InterfaceEvent
+	notifyChan chan interface{}
+	closeChan  chan interface{}
+	packetChan chan *comm.Packet
+	eventChan  chan comm.InterfaceEvent
+}
+
+// Create a new node and start delivering packets and events to it. The index
+// is used for generating addresses.
+func newNode(network *Network, name string, index int) *Node {
+
+	n := &Node{
+		network:    network,
+		name:       name,
+		index:      index,
+		addrs:      make(map[string]net.IP),
+		notifyChan: make(chan interface{}, 1),
+		closeChan:  make(chan interface{}),
+		packetChan: make(chan *comm.Packet),
+		eventChan:  make(chan comm.InterfaceEvent),
+	}
+
+	// Spawn a goroutine to deliver packets and events.
+	go n.run()
+
+	return n
+}
+
+// Send queued packets and events on their channels until closed.
+func (n *Node) run() {
+
+	defer close(n.packetChan)
+	defer close(n.eventChan)
+
+	for {
+
+		// Enable the channels for any values that are waiting to be sent.
+		var (
+			packetChan chan<- *comm.Packet
+			packet     *comm.Packet
+			eventChan  chan<- comm.InterfaceEvent
+			event      comm.InterfaceEvent
+		)
+		n.mutex.Lock()
+		if len(n.packets) != 0 {
+			packetChan = n.packetChan
+			packet = n.packets[0]
+		}
+		if len(n.events) != 0 {
+			eventChan = n.eventChan
+			event = n.events[0]
+		}
+		n.mutex.Unlock()
+
+		select {
+		case packetChan <- packet:
+			n.mutex.Lock()
+			n.packets = n.packets[1:]
+			n.mutex.Unlock()
+		case eventChan <- event:
+			n.mutex.Lock()
+			n.events = n.events[1:]
+			n.mutex.Unlock()
+		case <-n.notifyChan:
+		case <-n.closeChan:
+			return
+		}
+	}
+}
+
+// Wake the goroutine so that it picks up newly queued values.
+func (n *Node) notify() {
+	select {
+	case n.notifyChan <- nil:
+	default:
+	}
+}
+
+// Queue a packet for delivery.
+func (n *Node) deliver(pkt *comm.Packet) {
+	n.mutex.Lock()
+	n.packets = append(n.packets, pkt)
+	n.mutex.Unlock()
+	n.notify()
+}
+
+// Queue an interface event for delivery.
+func (n *Node) event(e comm.InterfaceEvent) {
+	n.mutex.Lock()
+	n.events = append(n.events, e)
+	n.mutex.Unlock()
+	n.notify()
+}
+
+// Attach the node to the named interface.
+func (n *Node) AddInterface(name string) {
+	n.network.mutex.Lock()
+	_, exists := n.addrs[name]
+	if !exists {
+		n.addrs[name] = n.network.addr(n, name)
+	}
+	n.network.mutex.Unlock()
+	if !exists {
+		n.event(comm.InterfaceEvent{Name: name, Added: true})
+	}
+}
+
+// Detach the node from the named interface.
+func (n *Node) RemoveInterface(name string) {
+	n.network.mutex.Lock()
+	_, exists := n.addrs[name]
+	if exists {
+		delete(n.addrs, name)
+	}
+	n.network.mutex.Unlock()
+	if exists {
+		n.event(comm.InterfaceEvent{Name: name})
+	}
+}
+
+// Obtain the address of the node on the named interface or nil if the node is
+// not attached to it.
+func (n *Node) Addr(name string) net.IP {
+	n.network.mutex.Lock()
+	defer n.network.mutex.Unlock()
+	return n.addrs[name]
+}
+
+// Send a packet to all reachable nodes.
+func (n *Node) Send(pkt *comm.Packet) {
+	n.network.send(n, pkt)
+}
+
+// Obtain the channel on which received packets are sent.
+func (n *Node) Receive() <-chan *comm.Packet {
+	return n.packetChan
+}
+
+// Obtain the channel on which interface additions and removals are sent.
+func (n *Node) Events() <-chan comm.InterfaceEvent {
+	return n.eventChan
+}
+
+// Detach the node from the network and stop delivering packets.
+func (n *Node) Close() {
+	n.network.remove(n)
+	close(n.closeChan)
+}
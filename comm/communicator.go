@@ -1,27 +1,115 @@
 package comm
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"net"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/nathan-osman/go-sdiscovery/metrics"
 	"github.com/nathan-osman/go-sdiscovery/util"
 )
 
 type connectionMap map[string][]*connection
 type connectionSlice []*connection
 
-// Manages connections on available network interfaces.
+// CommunicatorConfig contains the parameters that control how the
+// communicator behaves. If Clock is nil, util.SystemClock is used. On Linux,
+// interface changes are detected immediately using rtnetlink and polling at
+// PollInterval acts as a fallback. Other platforms rely on polling alone.
+//
+// Modes determines the types of packets used on each interface, unless the
+// interface has an entry in InterfaceModes. If Modes is zero, ModeDefault is
+// used. An entry in InterfaceModes of ModeDisabled (zero) turns discovery off
+// for that interface. Only interfaces that match Filter are used. Messages are
+// written to Logger, or util.DefaultLogger if it is nil.
+//
+// OnError is invoked for each problem that degrades discovery, such as a
+// packet that cannot be decoded or a socket that fails. It is invoked from
+// internal goroutines and must not block.
+//
+// If a socket fails, its connection is recreated after RetryInterval. The
+// delay doubles after each unsuccessful attempt up to MaxRetryInterval. Zero
+// values select one second and one minute respectively.
+//
+// Packets sent, received and dropped are recorded in Metrics, if provided.
+//
+// If Sniff is set, it is invoked for every datagram received, including those
+// that are dropped, which is useful for diagnosing why peers are not seen. It
+// is invoked from the goroutine reading each socket and must not block or
+// modify the datagram.
+type CommunicatorConfig struct {
+	PollInterval     time.Duration   // time between polling for network interfaces
+	Port             int             // port used for broadcast and multicast
+	Clock            util.Clock      // source of time for interface polling
+	Modes            Mode            // types of packets used on interfaces
+	InterfaceModes   map[string]Mode // types of packets used on specific interfaces
+	Multicast        MulticastConfig // parameters for multicast connections
+	Filter           InterfaceFilter // determines which interfaces are used
+	Logger           util.Logger     // destination for diagnostic messages
+	OnError          func(*Error)    // invoked when an error occurs
+	RetryInterval    time.Duration   // initial delay before recreating a failed connection
+	MaxRetryInterval time.Duration   // maximum delay before recreating a failed connection
+	Metrics          metrics.Metrics // destination for measurements
+	Sniff            func(*Datagram) // invoked for every datagram received
+}
+
+// Determine the types of packets to use for the named interface.
+func (c *CommunicatorConfig) mode(name string) Mode {
+	if mode, ok := c.InterfaceModes[name]; ok {
+		return mode
+	}
+	if c.Modes == 0 {
+		return ModeDefault
+	}
+	return c.Modes
+}
+
+// Report an error to the callback, if one was provided.
+func (c *CommunicatorConfig) reportError(err *Error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// Manages connections on available network interfaces. Communicator is the
+// default Transport implementation.
 type Communicator struct {
 	PacketChan  chan *Packet
 	sendChan    chan *Packet
+	eventChan   chan InterfaceEvent
+	deadChan    chan *connection
+	retryChan   chan *retry
+	infoChan    chan chan []ConnectionInfo
+	doneChan    chan struct{}
+	events      []InterfaceEvent
 	connections connectionMap
-	port        int
+	retries     map[string]map[packetType]*retry
+	config      CommunicatorConfig
+}
+
+// Create a fingerprint of the properties of an interface that affect its
+// connections. If the fingerprint changes, the connections must be recreated.
+func interfaceFingerprint(ifi *net.Interface) string {
+
+	// Obtain a sorted list of addresses so that the order is irrelevant.
+	var addrs []string
+	if ifiAddrs, err := ifi.Addrs(); err == nil {
+		for _, addr := range ifiAddrs {
+			addrs = append(addrs, addr.String())
+		}
+	}
+	sort.Strings(addrs)
+
+	return fmt.Sprintf("%s|%d|%s", ifi.Flags, ifi.MTU, strings.Join(addrs, ","))
 }
 
-// Return a list of interface names.
-func interfaceNames() (util.StrMap, error) {
+// Return a map of names of interfaces that match the filter to their
+// fingerprints.
+func (c *Communicator) interfaceFingerprints() (map[string]string, error) {
 
 	// Retrieve the current list of interfaces.
 	ifis, err := net.Interfaces()
@@ -29,41 +117,86 @@ func interfaceNames() (util.StrMap, error) {
 		return nil, err
 	}
 
-	// Create a map of the interface names.
-	newNames := make(util.StrMap)
-	for _, ifi := range ifis {
-		newNames[ifi.Name] = nil
+	// Create a map of the interface names and fingerprints.
+	fingerprints := make(map[string]string)
+	for i := range ifis {
+		if c.config.Filter.Match(&ifis[i]) {
+			fingerprints[ifis[i].Name] = interfaceFingerprint(&ifis[i])
+		}
 	}
 
-	return newNames, nil
+	return fingerprints, nil
 }
 
-// Create a new communicator.
+// Create a new communicator that polls for interfaces at the specified
+// interval and uses the default values for everything else.
 func NewCommunicator(pollInterval time.Duration, port int) *Communicator {
+	return NewCommunicatorWithConfig(CommunicatorConfig{
+		PollInterval: pollInterval,
+		Port:         port,
+	})
+}
+
+// Create a new communicator with the specified configuration.
+func NewCommunicatorWithConfig(config CommunicatorConfig) *Communicator {
+
+	// Use the system clock if none was provided.
+	if config.Clock == nil {
+		config.Clock = util.SystemClock
+	}
+
+	// Use the default logger if none was provided.
+	if config.Logger == nil {
+		config.Logger = util.DefaultLogger
+	}
+
+	// Discard measurements if there is nowhere to record them.
+	if config.Metrics == nil {
+		config.Metrics = metrics.Discard
+	}
 
 	// Create the communicator, including the channel that will be used
 	// for receiving the individual packets.
 	c := &Communicator{
 		PacketChan:  make(chan *Packet),
 		sendChan:    make(chan *Packet),
+		eventChan:   make(chan InterfaceEvent),
+		deadChan:    make(chan *connection),
+		retryChan:   make(chan *retry),
+		infoChan:    make(chan chan []ConnectionInfo),
+		doneChan:    make(chan struct{}),
 		connections: make(connectionMap),
-		port:        port,
+		retries:     make(map[string]map[packetType]*retry),
+		config:      config,
 	}
 
 	// Spawn a goroutine that manages connections.
-	go c.run(pollInterval)
+	go c.run()
 
 	return c
 }
 
 // Add and remove connections as interfaces are added and removed.
-func (c *Communicator) run(pollInterval time.Duration) {
+func (c *Communicator) run() {
 
-	// Enumerate interface additions and removals.
-	ticker := time.NewTicker(pollInterval)
-	defer ticker.Stop()
+	// Enumerate interface additions and removals each time the watcher
+	// indicates that interfaces may have changed.
+	watcher := newInterfaceWatcher(c.config.Clock, c.config.PollInterval, c.config.Logger)
+	defer watcher.Stop()
 
-	ifiEnum := util.NewStrEnum(ticker.C, interfaceNames)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	enum := func() (map[string]string, error) {
+		m, err := c.interfaceFingerprints()
+		if err != nil {
+			c.config.reportError(&Error{Kind: ErrInterface, Err: err})
+		}
+		return m, err
+	}
+	ifiWatcher := util.NewSetWatcher(ctx, watcher.C(), enum, func(a, b string) bool {
+		return a == b
+	}, c.config.Logger)
 
 	// Create a WaitGroup for each of the sockets so that we can ensure all of
 	// them end before closing the packet channel.
@@ -71,11 +204,42 @@ func (c *Communicator) run(pollInterval time.Duration) {
 
 loop:
 	for {
+
+		// If there are interface events waiting to be sent, enable the
+		// channel in the select below. A nil channel is never selected.
+		var (
+			eventChan chan<- InterfaceEvent
+			event     InterfaceEvent
+		)
+		if len(c.events) != 0 {
+			eventChan = c.eventChan
+			event = c.events[0]
+		}
+
 		select {
-		case name := <-ifiEnum.StringAdded:
-			c.addInterface(name, &waitGroup)
-		case name := <-ifiEnum.StringRemoved:
-			c.removeInterface(name)
+		case eventChan <- event:
+			c.events = c.events[1:]
+		case changes := <-ifiWatcher.Changes:
+			for _, change := range changes {
+				switch change.Type {
+				case util.EntryAdded:
+					c.addInterface(change.Key, &waitGroup)
+				case util.EntryRemoved:
+					c.removeInterface(change.Key)
+				case util.EntryChanged:
+
+					// Recreate the connections since addresses or flags
+					// changed.
+					c.removeInterface(change.Key)
+					c.addInterface(change.Key, &waitGroup)
+				}
+			}
+		case conn := <-c.deadChan:
+			c.removeDeadConnection(ctx, conn)
+		case r := <-c.retryChan:
+			c.retryConnection(ctx, r, &waitGroup)
+		case reply := <-c.infoChan:
+			reply <- c.connectionInfo()
 		case data, ok := <-c.sendChan:
 
 			// If the receive was successful, send the packet on each of the
@@ -83,7 +247,12 @@ loop:
 			if ok {
 				for _, connections := range c.connections {
 					for _, conn := range connections {
-						conn.send(data)
+						if err := conn.send(data); err != nil {
+							c.config.Logger.Debug("unable to send packet", "interface", conn.name, "type", conn.pType, "error", err)
+							c.config.reportError(&Error{Kind: ErrSocket, Interface: conn.name, Addr: conn.addr, Err: err})
+						} else {
+							c.config.Metrics.PacketSent(conn.name, conn.pType.String())
+						}
 					}
 				}
 			} else {
@@ -97,35 +266,85 @@ loop:
 		c.removeInterface(name)
 	}
 
-	// Wait for the connections to finish then close the channel.
+	// Wait for the connections to finish then close the channels.
 	waitGroup.Wait()
 	close(c.PacketChan)
+	close(c.eventChan)
+	close(c.doneChan)
+}
+
+// Describe each of the connections, sorted by interface. The connections are
+// owned by the goroutine in run(), which must be the caller.
+func (c *Communicator) connectionInfo() []ConnectionInfo {
+	infos := []ConnectionInfo{}
+	for name, connections := range c.connections {
+		for _, conn := range connections {
+			infos = append(infos, ConnectionInfo{
+				Interface: name,
+				Type:      conn.pType.String(),
+				Addr:      conn.addr.String(),
+			})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Interface != infos[j].Interface {
+			return infos[i].Interface < infos[j].Interface
+		}
+		return infos[i].Type < infos[j].Type
+	})
+	return infos
+}
+
+// Obtain a description of each connection currently in use. Nil is returned
+// once the communicator has shut down.
+func (c *Communicator) Connections() []ConnectionInfo {
+	reply := make(chan []ConnectionInfo, 1)
+	select {
+	case c.infoChan <- reply:
+		return <-reply
+	case <-c.doneChan:
+		return nil
+	}
 }
 
 // Add connections for the specified interface.
 func (c *Communicator) addInterface(name string, waitGroup *sync.WaitGroup) {
 
-	// Assume that most interfaces will have at most two addresses.
-	connections := make(connectionSlice, 0, 2)
+	// Assume that most interfaces will have at most three connections.
+	connections := make(connectionSlice, 0, 3)
 
 	// Attempt to find the interface by name.
 	ifi, err := net.InterfaceByName(name)
 	if err != nil {
-		log.Println("[ERR]", err)
+		c.config.Logger.Error("unable to find interface", "interface", name, "error", err)
+		c.config.reportError(&Error{Kind: ErrInterface, Interface: name, Err: err})
 		return
 	}
 
-	// Add a connection for broadcast and multicast addresses if present.
-	if ifi.Flags&net.FlagMulticast != 0 {
-		if conn, err := newConnection(c.PacketChan, waitGroup, ifi, c.port, multicast); err != nil {
-			log.Println("[WARN]", err)
-		} else {
-			connections = append(connections, conn)
-		}
+	// Interfaces that are down cannot send or receive packets. They will be
+	// added again when their fingerprint changes.
+	if ifi.Flags&net.FlagUp == 0 {
+		return
 	}
-	if ifi.Flags&net.FlagBroadcast != 0 {
-		if conn, err := newConnection(c.PacketChan, waitGroup, ifi, c.port, broadcast); err != nil {
-			log.Println("[WARN]", err)
+
+	// Add a connection for each type of packet enabled for the interface if
+	// the interface supports it.
+	mode := c.config.mode(name)
+	for _, t := range []struct {
+		mode  Mode
+		flag  net.Flags
+		pType packetType
+	}{
+		{ModeMulticastIPv6, net.FlagMulticast, multicast},
+		{ModeBroadcastIPv4, net.FlagBroadcast, broadcast},
+		{ModeMulticastIPv4, net.FlagMulticast, multicastIPv4},
+	} {
+		if mode&t.mode == 0 || ifi.Flags&t.flag == 0 {
+			continue
+		}
+		if conn, err := newConnection(c.PacketChan, c.deadChan, waitGroup, ifi, &c.config, t.pType); err != nil {
+			c.config.Logger.Warn("unable to create connection", "interface", name, "type", t.pType, "error", err)
+			c.config.reportError(&Error{Kind: ErrInterface, Interface: name, Err: err})
 		} else {
 			connections = append(connections, conn)
 		}
@@ -134,12 +353,17 @@ func (c *Communicator) addInterface(name string, waitGroup *sync.WaitGroup) {
 	// Create a new entry in the map for the connections (if any).
 	if len(connections) != 0 {
 		c.connections[name] = connections
+		c.config.Logger.Debug("interface added", "interface", name, "connections", len(connections))
+		c.events = append(c.events, InterfaceEvent{Name: name, Added: true})
 	}
 }
 
 // Remove all connections for the specified interface.
 func (c *Communicator) removeInterface(name string) {
 
+	// Connections that failed should no longer be recreated.
+	c.cancelRetries(name)
+
 	// Check if the interface exists.
 	if connections, ok := c.connections[name]; ok {
 
@@ -150,6 +374,8 @@ func (c *Communicator) removeInterface(name string) {
 
 		// Remove the item from the map.
 		delete(c.connections, name)
+		c.config.Logger.Debug("interface removed", "interface", name)
+		c.events = append(c.events, InterfaceEvent{Name: name})
 	}
 }
 
@@ -158,7 +384,24 @@ func (c *Communicator) Send(pkt *Packet) {
 	c.sendChan <- pkt
 }
 
+// Obtain the channel on which received packets are sent.
+func (c *Communicator) Receive() <-chan *Packet {
+	return c.PacketChan
+}
+
+// Obtain the channel on which interface additions and removals are sent.
+func (c *Communicator) Events() <-chan InterfaceEvent {
+	return c.eventChan
+}
+
 // Stop the goroutine by closing the send channel.
-func (c *Communicator) Stop() {
+func (c *Communicator) Close() {
 	close(c.sendChan)
 }
+
+// Stop the goroutine by closing the send channel.
+//
+// Deprecated: use Close, which satisfies the Transport interface.
+func (c *Communicator) Stop() {
+	c.Close()
+}
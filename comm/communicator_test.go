@@ -1,8 +1,12 @@
 package comm
 
 import (
+	"context"
+	"net"
 	"testing"
 	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/util"
 )
 
 // Ensure that the Communicator class can be instantiated and terminated.
@@ -10,3 +14,110 @@ func Test_Communicator(t *testing.T) {
 	c := NewCommunicator(time.Second, 8000)
 	defer c.Stop()
 }
+
+// Ensure that interface-specific modes override the default.
+func Test_CommunicatorConfig_mode(t *testing.T) {
+	c := &CommunicatorConfig{
+		Modes:          ModeMulticastIPv4,
+		InterfaceModes: map[string]Mode{"eth0": ModeBroadcastIPv4, "eth2": ModeDisabled},
+	}
+	if c.mode("eth0") != ModeBroadcastIPv4 || c.mode("eth1") != ModeMulticastIPv4 {
+		t.Fatal("Incorrect mode")
+	}
+	if c.mode("eth2") != ModeDisabled {
+		t.Fatal("Expected interface to be disabled")
+	}
+	if (&CommunicatorConfig{}).mode("eth0") != ModeDefault {
+		t.Fatal("Expected default mode")
+	}
+}
+
+// Ensure that the fingerprint changes when interface properties change.
+func Test_interfaceFingerprint(t *testing.T) {
+	a := interfaceFingerprint(&net.Interface{Name: "test0", MTU: 1500})
+	b := interfaceFingerprint(&net.Interface{Name: "test0", MTU: 1500, Flags: net.FlagUp})
+	c := interfaceFingerprint(&net.Interface{Name: "test0", MTU: 9000, Flags: net.FlagUp})
+	if a == b || b == c {
+		t.Fatal("Fingerprint did not change")
+	}
+}
+
+// Ensure that the retry delay doubles up to the maximum.
+func Test_CommunicatorConfig_retryDelay(t *testing.T) {
+	c := &CommunicatorConfig{
+		RetryInterval:    time.Second,
+		MaxRetryInterval: 5 * time.Second,
+	}
+	for i, d := range []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		5 * time.Second,
+		5 * time.Second,
+	} {
+		if v := c.retryDelay(i); v != d {
+			t.Fatalf("%d: %s != %s", i, v, d)
+		}
+	}
+	if (&CommunicatorConfig{}).retryDelay(0) != defaultRetryInterval {
+		t.Fatal("Expected default retry interval")
+	}
+}
+
+// Ensure that dead connections are removed and that their retries are
+// cancelled when the interface is removed.
+func Test_Communicator_removeDeadConnection(t *testing.T) {
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	dead := &connection{
+		stopChan: make(chan interface{}),
+		conn:     conn,
+		name:     "lo",
+		pType:    broadcast,
+	}
+	c := &Communicator{
+		retryChan:   make(chan *retry),
+		connections: connectionMap{"lo": {dead}},
+		retries:     make(map[string]map[packetType]*retry),
+		config: CommunicatorConfig{
+			Clock:  util.NewFakeClock(time.Unix(0, 0)),
+			Logger: util.DiscardLogger,
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c.removeDeadConnection(ctx, dead)
+	if len(c.connections["lo"]) != 0 {
+		t.Fatal("Connection was not removed")
+	}
+	if c.retries["lo"][broadcast] == nil {
+		t.Fatal("Retry was not scheduled")
+	}
+
+	c.removeInterface("lo")
+	if len(c.retries) != 0 {
+		t.Fatal("Retry was not cancelled")
+	}
+}
+
+// Ensure that connections can be listed while running and after shutdown.
+func Test_Communicator_Connections(t *testing.T) {
+	c := NewCommunicatorWithConfig(CommunicatorConfig{
+		PollInterval: time.Second,
+		Port:         8000,
+		Filter:       InterfaceFilter{Include: []string{"-"}},
+	})
+	if infos := c.Connections(); infos == nil || len(infos) != 0 {
+		t.Fatal("Expected an empty list of connections")
+	}
+	c.Close()
+	for range c.Receive() {
+	}
+	if c.Connections() != nil {
+		t.Fatal("Expected no connections after shutdown")
+	}
+}
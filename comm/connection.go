@@ -1,6 +1,8 @@
 package comm
 
 import (
+	"context"
+	"fmt"
 	"net"
 	"sync"
 
@@ -12,55 +14,145 @@ type packetType int
 const (
 	multicast packetType = iota
 	broadcast
+	multicastIPv4
 )
 
+// Return a description of the packet type for use in log messages.
+func (p packetType) String() string {
+	switch p {
+	case multicast:
+		return "multicast"
+	case broadcast:
+		return "broadcast"
+	case multicastIPv4:
+		return "multicast4"
+	}
+	return "unknown"
+}
+
 // connection provides methods for sending and receiving packets from a
 // specific address on a network interface.
 type connection struct {
 	stopChan chan interface{}
 	conn     *net.UDPConn
+	addr     *net.UDPAddr
+	networks []*net.IPNet
+	name     string
+	pType    packetType
+	config   *CommunicatorConfig
 }
 
 // Create a new multicast (IPv6) connection to the specified interface.
-func multicastConnection(ifi *net.Interface, port int) (*net.UDPConn, error) {
+func multicastConnection(ifi *net.Interface, port int, config *MulticastConfig) (*net.UDPConn, *net.UDPAddr, error) {
+
+	// Determine the group address to use.
+	ip, err := config.groupIPv6()
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Use the all nodes link-local IPv6 address.
-	return net.ListenMulticastUDP("udp6", ifi, &net.UDPAddr{
-		IP:   net.IPv6linklocalallnodes,
+	// Join the group on the interface.
+	addr := &net.UDPAddr{
+		IP:   ip,
 		Port: port,
-	})
+		Zone: ifi.Name,
+	}
+	conn, err := net.ListenMulticastUDP("udp6", ifi, addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Apply the hop limit and loopback options.
+	if err := setMulticastOptionsIPv6(conn, config.hopLimit(), config.Loopback); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, addr, nil
 }
 
-// Create a new broadcast (IPv4) connection to the specified interface.
-func broadcastConnection(ifi *net.Interface, port int) (*net.UDPConn, error) {
+// Create a new broadcast (IPv4) connection to the specified interface. The
+// socket is bound to the wildcard address, since binding to the broadcast
+// address prevents packets from being sent with the correct source address.
+// Because the socket receives packets from every interface, the networks on
+// the interface are also returned so that packets can be filtered by source.
+func broadcastConnection(ifi *net.Interface, port int) (*net.UDPConn, *net.UDPAddr, []*net.IPNet, error) {
 
 	// Attempt to find an IPv4 broadcast address.
 	ip, err := util.FindBroadcastAddress(ifi)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
+	}
+
+	// Find the networks for the interface.
+	networks, err := util.FindIPv4Networks(ifi)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Bind to the port, allowing other sockets to do the same but ignoring
+	// multicast datagrams meant for them.
+	lc := &net.ListenConfig{Control: controlBroadcast}
+	conn, err := lc.ListenPacket(context.Background(), "udp4", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Send packets to the broadcast address that was found.
+	addr := &net.UDPAddr{
+		IP:   ip,
+		Port: port,
+	}
+
+	return conn.(*net.UDPConn), addr, networks, nil
+}
+
+// Create a new multicast (IPv4) connection to the specified interface.
+func multicastIPv4Connection(ifi *net.Interface, port int, config *MulticastConfig) (*net.UDPConn, *net.UDPAddr, error) {
+
+	// Determine the group address to use.
+	ip, err := config.groupIPv4()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Use the broadcast address that was found.
-	return net.ListenUDP("udp4", &net.UDPAddr{
+	// Join the group on the interface.
+	addr := &net.UDPAddr{
 		IP:   ip,
 		Port: port,
-	})
+	}
+	conn, err := net.ListenMulticastUDP("udp4", ifi, addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Apply the TTL and loopback options.
+	if err := setMulticastOptionsIPv4(conn, config.ttl(), config.Loopback); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, addr, nil
 }
 
 // Create a new connection for sending and receiving packets.
-func newConnection(packetChan chan<- *Packet, waitGroup *sync.WaitGroup, ifi *net.Interface, port int, pType packetType) (*connection, error) {
+func newConnection(packetChan chan<- *Packet, deadChan chan<- *connection, waitGroup *sync.WaitGroup, ifi *net.Interface, config *CommunicatorConfig, pType packetType) (*connection, error) {
 
 	var (
-		conn *net.UDPConn
-		err  error
+		conn     *net.UDPConn
+		addr     *net.UDPAddr
+		networks []*net.IPNet
+		err      error
 	)
 
 	// Use the appropriate initializer.
 	switch pType {
 	case multicast:
-		conn, err = multicastConnection(ifi, port)
+		conn, addr, err = multicastConnection(ifi, config.Port, &config.Multicast)
 	case broadcast:
-		conn, err = broadcastConnection(ifi, port)
+		conn, addr, networks, err = broadcastConnection(ifi, config.Port)
+	case multicastIPv4:
+		conn, addr, err = multicastIPv4Connection(ifi, config.Port, &config.Multicast)
 	}
 
 	// Check for an error.
@@ -72,16 +164,37 @@ func newConnection(packetChan chan<- *Packet, waitGroup *sync.WaitGroup, ifi *ne
 	c := &connection{
 		stopChan: make(chan interface{}),
 		conn:     conn,
+		addr:     addr,
+		networks: networks,
+		name:     ifi.Name,
+		pType:    pType,
+		config:   config,
 	}
 
 	// Spawn a goroutine to read from the socket.
-	go c.run(packetChan, waitGroup)
+	go c.run(packetChan, deadChan, waitGroup)
 
 	return c, nil
 }
 
-// Continuously read packets from the connection.
-func (c *connection) run(packetChan chan<- *Packet, waitGroup *sync.WaitGroup) {
+// Determine if a packet from the specified address belongs to the interface.
+// Only sockets bound to the wildcard address have networks to check.
+func (c *connection) fromInterface(ip net.IP) bool {
+	if c.networks == nil {
+		return true
+	}
+	for _, network := range c.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Continuously read packets from the connection. If reading fails before the
+// connection is stopped, the connection is sent to deadChan so that it can be
+// recreated.
+func (c *connection) run(packetChan chan<- *Packet, deadChan chan<- *connection, waitGroup *sync.WaitGroup) {
 
 	// Ensure that the WaitGroup is properly updated.
 	waitGroup.Add(1)
@@ -90,18 +203,30 @@ func (c *connection) run(packetChan chan<- *Packet, waitGroup *sync.WaitGroup) {
 loop:
 	for {
 
-		// Put a hard cap of 1000 bytes on the packet size.
-		b := make([]byte, 1000)
+		// Put a hard cap of 1000 bytes on the packet size. One extra byte is
+		// read so that oversized datagrams can be detected.
+		b := make([]byte, maxPacketSize+1)
 
-		// Read the packet, quitting on error.
+		// Read the packet, quitting on error. An error is expected when
+		// the connection is stopped.
 		n, addr, err := c.conn.ReadFromUDP(b)
 		if err != nil {
+			select {
+			case <-c.stopChan:
+			default:
+				c.config.Logger.Warn("unable to read packet", "interface", c.name, "type", c.pType, "error", err)
+				c.config.reportError(&Error{Kind: ErrSocket, Interface: c.name, Err: err})
+				select {
+				case deadChan <- c:
+				case <-c.stopChan:
+				}
+			}
 			break
 		}
 
-		// Attempt to create the packet.
-		pkt, err := NewPacketFromJSON(addr.IP, b[:n])
-		if err != nil {
+		// Attempt to create the packet, skipping datagrams that are dropped.
+		pkt := c.decode(addr, b[:n])
+		if pkt == nil {
 			continue
 		}
 
@@ -114,6 +239,52 @@ loop:
 	}
 }
 
+// Decode a datagram received from the specified address. Nil is returned if
+// the datagram should be dropped. If sniffing is enabled, the datagram and the
+// outcome are reported regardless.
+func (c *connection) decode(addr *net.UDPAddr, data []byte) *Packet {
+
+	d := &Datagram{
+		Interface: c.name,
+		Type:      c.pType.String(),
+		Addr:      addr,
+		Dest:      c.addr,
+		Data:      data,
+	}
+	if c.config.Sniff != nil {
+		d.Time = c.config.Clock.Now()
+		defer c.config.Sniff(d)
+	}
+
+	// Ignore packets that are too large or arrived on a different interface.
+	switch {
+	case len(data) > maxPacketSize:
+		d.Dropped = DropSize
+	case !c.fromInterface(addr.IP):
+		d.Dropped = DropInterface
+	}
+	if d.Dropped != "" {
+		c.config.Metrics.PacketDropped(c.name, d.Type, d.Dropped)
+		return nil
+	}
+
+	// Attempt to create the packet.
+	pkt, err := NewPacketFromJSON(addr.IP, data)
+	if err != nil {
+		c.config.Logger.Debug("unable to decode packet", "interface", c.name, "addr", addr, "error", err)
+		c.config.reportError(&Error{Kind: ErrDecode, Interface: c.name, Addr: addr, Err: err})
+		c.config.Metrics.DecodeError(c.name, d.Type)
+		d.Err, d.Dropped = err, DropDecode
+		return nil
+	}
+	pkt.Interface = c.name
+	pkt.Type = d.Type
+	c.config.Metrics.PacketReceived(c.name, pkt.Type)
+	d.Packet = pkt
+
+	return pkt
+}
+
 // Send a packet.
 func (c *connection) send(pkt *Packet) error {
 
@@ -123,13 +294,13 @@ func (c *connection) send(pkt *Packet) error {
 		return err
 	}
 
-	// Write the packet to the connection.
-	_, err = c.conn.WriteToUDP(data, c.conn.LocalAddr().(*net.UDPAddr))
+	// Write the packet to the broadcast or multicast address.
+	_, err = c.conn.WriteToUDP(data, c.addr)
 	return err
 }
 
 // Stop listening for incoming packets.
 func (c *connection) stop() {
-	c.conn.Close()
 	close(c.stopChan)
+	c.conn.Close()
 }
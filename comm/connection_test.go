@@ -0,0 +1,293 @@
+package comm
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/metrics"
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Find an interface that supports multicast, skipping the test if none exist.
+func findMulticastInterface(t *testing.T) *net.Interface {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ifi := range ifis {
+		if ifi.Flags&net.FlagUp != 0 && ifi.Flags&net.FlagMulticast != 0 &&
+			ifi.Flags&net.FlagLoopback == 0 {
+			return &ifi
+		}
+	}
+	t.Skip("No multicast interface available")
+	return nil
+}
+
+// Ensure that packets sent on an IPv4 multicast connection with loopback
+// enabled are received on the same machine.
+func Test_multicastIPv4Connection(t *testing.T) {
+
+	ifi := findMulticastInterface(t)
+	conn, addr, err := multicastIPv4Connection(ifi, 8001, &MulticastConfig{
+		Loopback: true,
+	})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	// Ensure that the default group is used.
+	if !addr.IP.Equal(DefaultGroupIPv4) {
+		t.Fatal("Expected default group address")
+	}
+
+	// Send a packet and then attempt to read it.
+	if _, err := conn.WriteToUDP([]byte("test"), addr); err != nil {
+		t.Skip(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 10)
+	n, _, err := conn.ReadFromUDP(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:n]) != "test" {
+		t.Fatal("Incorrect data received")
+	}
+}
+
+// Ensure that the scope is applied to the IPv6 group address.
+func Test_MulticastConfig_groupIPv6(t *testing.T) {
+
+	// The default group should be used if none is provided.
+	c := &MulticastConfig{}
+	if ip, err := c.groupIPv6(); err != nil || !ip.Equal(DefaultGroupIPv6) {
+		t.Fatal("Expected default group address")
+	}
+
+	// The scope should replace that of the group address.
+	c = &MulticastConfig{
+		GroupIPv6: net.ParseIP("ff02::5344"),
+		Scope:     ScopeSiteLocal,
+	}
+	if ip, err := c.groupIPv6(); err != nil || !ip.Equal(net.ParseIP("ff05::5344")) {
+		t.Fatal("Scope was not applied")
+	}
+	if !c.GroupIPv6.Equal(net.ParseIP("ff02::5344")) {
+		t.Fatal("Original address was modified")
+	}
+
+	// Non-multicast addresses should be rejected.
+	c = &MulticastConfig{GroupIPv6: net.ParseIP("fe80::1")}
+	if _, err := c.groupIPv6(); err == nil {
+		t.Fatal("Expected error for unicast address")
+	}
+}
+
+// Find an interface that supports broadcast, skipping the test if none exist.
+func findBroadcastInterface(t *testing.T) *net.Interface {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ifi := range ifis {
+		if ifi.Flags&net.FlagUp != 0 && ifi.Flags&net.FlagBroadcast != 0 {
+			return &ifi
+		}
+	}
+	t.Skip("No broadcast interface available")
+	return nil
+}
+
+// Ensure that two broadcast connections can share a port and that both
+// receive packets sent to the broadcast address.
+func Test_broadcastConnection(t *testing.T) {
+
+	ifi := findBroadcastInterface(t)
+
+	// Create two connections on the same port.
+	conn1, addr, _, err := broadcastConnection(ifi, 8002)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn1.Close()
+	conn2, _, _, err := broadcastConnection(ifi, 8002)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+
+	// Send a packet to the broadcast address.
+	if _, err := conn1.WriteToUDP([]byte("test"), addr); err != nil {
+		t.Skip(err)
+	}
+
+	// Ensure that both connections receive it.
+	for _, conn := range []*net.UDPConn{conn1, conn2} {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		b := make([]byte, 10)
+		n, _, err := conn.ReadFromUDP(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b[:n]) != "test" {
+			t.Fatal("Incorrect data received")
+		}
+	}
+}
+
+// Ensure that malformed packets are reported with their source address.
+func Test_connection_decodeError(t *testing.T) {
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	errChan := make(chan *Error, 1)
+	c := &connection{
+		stopChan: make(chan interface{}),
+		conn:     conn,
+		name:     "lo",
+		config: &CommunicatorConfig{
+			Logger:  util.DiscardLogger,
+			Metrics: metrics.Discard,
+			OnError: func(e *Error) { errChan <- e },
+		},
+	}
+	var waitGroup sync.WaitGroup
+	go c.run(make(chan *Packet), make(chan *connection), &waitGroup)
+	defer c.stop()
+
+	// Send a packet that is not valid JSON.
+	sender, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	if _, err := sender.Write([]byte("{")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-errChan:
+		if !errors.Is(e, ErrDecode) || e.Interface != "lo" {
+			t.Fatal("Incorrect error reported")
+		}
+		if e.Addr.String() != sender.LocalAddr().String() {
+			t.Fatal("Incorrect address reported")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No error was reported")
+	}
+}
+
+// Ensure that a connection whose socket fails is reported as dead.
+func Test_connection_dead(t *testing.T) {
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	c := &connection{
+		stopChan: make(chan interface{}),
+		conn:     conn,
+		name:     "lo",
+		config:   &CommunicatorConfig{Logger: util.DiscardLogger, Metrics: metrics.Discard},
+	}
+	var waitGroup sync.WaitGroup
+	deadChan := make(chan *connection)
+	go c.run(make(chan *Packet), deadChan, &waitGroup)
+
+	// Close the socket without stopping the connection.
+	conn.Close()
+	select {
+	case dead := <-deadChan:
+		if dead != c {
+			t.Fatal("Incorrect connection reported")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Connection was not reported as dead")
+	}
+	c.stop()
+}
+
+// Ensure that every datagram is reported to the sniffer with the reason it
+// was dropped.
+func Test_connection_decode(t *testing.T) {
+
+	var d *Datagram
+	_, network, _ := net.ParseCIDR("192.168.1.0/24")
+	c := &connection{
+		name:     "eth0",
+		pType:    broadcast,
+		networks: []*net.IPNet{network},
+		config: &CommunicatorConfig{
+			Clock:   util.SystemClock,
+			Logger:  util.DiscardLogger,
+			Metrics: metrics.Discard,
+			Sniff:   func(v *Datagram) { d = v },
+		},
+	}
+	local := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 2), Port: 8000}
+	remote := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 8000}
+	for _, v := range []struct {
+		addr    *net.UDPAddr
+		data    []byte
+		dropped string
+	}{
+		{local, []byte(`{"id":"a"}`), ""},
+		{local, make([]byte, maxPacketSize+1), DropSize},
+		{remote, []byte(`{"id":"a"}`), DropInterface},
+		{local, []byte(`{`), DropDecode},
+	} {
+		pkt := c.decode(v.addr, v.data)
+		if d == nil || d.Dropped != v.dropped || d.Interface != "eth0" || d.Type != "broadcast" {
+			t.Fatalf("Incorrect datagram for %q", v.dropped)
+		}
+		if (pkt == nil) != (v.dropped != "") || (d.Packet == nil) != (v.dropped != "") {
+			t.Fatalf("Incorrect packet for %q", v.dropped)
+		}
+		d = nil
+	}
+}
+
+// Ensure that a broadcast connection does not receive datagrams sent to an
+// IPv4 multicast group joined by another connection on the same port.
+func Test_broadcastConnection_multicast(t *testing.T) {
+
+	ifi := findMulticastInterface(t)
+	if ifi.Flags&net.FlagBroadcast == 0 {
+		t.Skip("Interface does not support broadcast")
+	}
+	bConn, _, _, err := broadcastConnection(ifi, 8004)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer bConn.Close()
+	mConn, addr, err := multicastIPv4Connection(ifi, 8004, &MulticastConfig{
+		Loopback: true,
+	})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer mConn.Close()
+
+	// Send a packet to the group and ensure that only the multicast
+	// connection receives it.
+	if _, err := mConn.WriteToUDP([]byte("test"), addr); err != nil {
+		t.Skip(err)
+	}
+	mConn.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 10)
+	if _, _, err := mConn.ReadFromUDP(b); err != nil {
+		t.Skip(err)
+	}
+	bConn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, _, err := bConn.ReadFromUDP(b); err == nil {
+		t.Fatal("Broadcast connection received a multicast packet")
+	}
+}
@@ -0,0 +1,62 @@
+package comm
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+var (
+	// ErrDecode is reported when a datagram received is not a valid packet.
+	ErrDecode = errors.New("Unable to decode packet")
+
+	// ErrSocket is reported when a connection fails or cannot be recreated.
+	ErrSocket = errors.New("Socket error")
+
+	// ErrInterface is reported when an interface cannot be listed or used.
+	ErrInterface = errors.New("Unable to use interface")
+)
+
+// Error describes a problem that degrades discovery, such as a malformed
+// packet or a socket that could not be created. Kind is one of ErrDecode,
+// ErrSocket or ErrInterface and can be tested for with errors.Is.
+type Error struct {
+	Kind      error    // category of the error
+	Interface string   // name of the interface (if known)
+	Addr      net.Addr // remote address (if known)
+	Err       error    // underlying error
+}
+
+// Describe the error, including the interface and address if known. If Kind
+// is nil, only the underlying error is described.
+func (e *Error) Error() string {
+	if e.Kind == nil {
+		if e.Err == nil {
+			return "Unknown error"
+		}
+		return e.Err.Error()
+	}
+	s := e.Kind.Error()
+	if e.Interface != "" {
+		s = fmt.Sprintf("%s on %s", s, e.Interface)
+	}
+	if e.Addr != nil {
+		s = fmt.Sprintf("%s (%s)", s, e.Addr)
+	}
+	if e.Err != nil {
+		s = fmt.Sprintf("%s: %s", s, e.Err)
+	}
+	return s
+}
+
+// Allow both the kind and the underlying error to be matched by errors.Is and
+// errors.As.
+func (e *Error) Unwrap() []error {
+	var errs []error
+	for _, err := range []error{e.Kind, e.Err} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
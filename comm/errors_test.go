@@ -0,0 +1,36 @@
+package comm
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// Ensure that errors can be matched by kind and cause.
+func Test_Error(t *testing.T) {
+	cause := errors.New("refused")
+	err := error(&Error{
+		Kind:      ErrSocket,
+		Interface: "eth0",
+		Addr:      &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 8000},
+		Err:       cause,
+	})
+	if !errors.Is(err, ErrSocket) || errors.Is(err, ErrDecode) {
+		t.Fatal("Error kind does not match")
+	}
+	if !errors.Is(err, cause) {
+		t.Fatal("Underlying error does not match")
+	}
+	if s := err.Error(); s != "Socket error on eth0 (192.0.2.1:8000): refused" {
+		t.Fatal(s)
+	}
+
+	// An error without a kind should only describe the underlying error.
+	err = &Error{Interface: "eth0", Err: cause}
+	if s := err.Error(); s != "refused" || !errors.Is(err, cause) {
+		t.Fatal(s)
+	}
+	if s := (&Error{}).Error(); s != "Unknown error" {
+		t.Fatal(s)
+	}
+}
@@ -0,0 +1,69 @@
+package comm
+
+import (
+	"net"
+	"path"
+)
+
+// InterfaceFilter determines which network interfaces are used. An interface
+// must satisfy every criterion that is set; the zero value accepts all
+// interfaces. The filter is evaluated each time interfaces are polled, so an
+// interface that stops matching is removed.
+//
+// Include and Exclude contain glob patterns (such as "docker*") matched
+// against the interface name using path.Match.
+type InterfaceFilter struct {
+	Include      []string                  // name patterns, one of which must match
+	Exclude      []string                  // name patterns, none of which may match
+	RequireFlags net.Flags                 // flags that must be set (such as net.FlagUp)
+	RejectFlags  net.Flags                 // flags that must not be set (such as net.FlagLoopback)
+	Networks     []*net.IPNet              // ranges, one of which must contain an address
+	Func         func(*net.Interface) bool // arbitrary test applied last
+}
+
+// Determine if the name matches any of the patterns.
+func matchName(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Determine if any of the interface addresses are within the networks.
+func matchNetworks(ifi *net.Interface, networks []*net.IPNet) bool {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		for _, network := range networks {
+			if network.Contains(ipNet.IP) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Determine if the interface satisfies the filter.
+func (f *InterfaceFilter) Match(ifi *net.Interface) bool {
+	if len(f.Include) != 0 && !matchName(ifi.Name, f.Include) {
+		return false
+	}
+	if matchName(ifi.Name, f.Exclude) {
+		return false
+	}
+	if ifi.Flags&f.RequireFlags != f.RequireFlags || ifi.Flags&f.RejectFlags != 0 {
+		return false
+	}
+	if len(f.Networks) != 0 && !matchNetworks(ifi, f.Networks) {
+		return false
+	}
+	return f.Func == nil || f.Func(ifi)
+}
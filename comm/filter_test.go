@@ -0,0 +1,76 @@
+package comm
+
+import (
+	"net"
+	"testing"
+)
+
+// Ensure that each of the name and flag criteria is applied.
+func Test_InterfaceFilter_Match(t *testing.T) {
+
+	eth0 := &net.Interface{Name: "eth0", Flags: net.FlagUp | net.FlagBroadcast}
+	docker0 := &net.Interface{Name: "docker0", Flags: net.FlagUp}
+	tun0 := &net.Interface{Name: "tun0", Flags: net.FlagPointToPoint}
+
+	for _, c := range []struct {
+		name    string
+		filter  InterfaceFilter
+		matches []*net.Interface
+	}{
+		{"zero value", InterfaceFilter{}, []*net.Interface{eth0, docker0, tun0}},
+		{"include", InterfaceFilter{Include: []string{"eth*", "tun?"}}, []*net.Interface{eth0, tun0}},
+		{"exclude", InterfaceFilter{Exclude: []string{"docker*"}}, []*net.Interface{eth0, tun0}},
+		{"require", InterfaceFilter{RequireFlags: net.FlagUp}, []*net.Interface{eth0, docker0}},
+		{"reject", InterfaceFilter{RejectFlags: net.FlagPointToPoint}, []*net.Interface{eth0, docker0}},
+		{"func", InterfaceFilter{Func: func(ifi *net.Interface) bool {
+			return ifi.Name == "docker0"
+		}}, []*net.Interface{docker0}},
+	} {
+		matches := 0
+		for _, ifi := range []*net.Interface{eth0, docker0, tun0} {
+			if c.filter.Match(ifi) {
+				matches++
+				found := false
+				for _, m := range c.matches {
+					found = found || m == ifi
+				}
+				if !found {
+					t.Fatalf("Filter %s matched %s", c.name, ifi.Name)
+				}
+			}
+		}
+		if matches != len(c.matches) {
+			t.Fatalf("Filter %s matched %d interfaces", c.name, matches)
+		}
+	}
+}
+
+// Ensure that interfaces are matched by address range.
+func Test_InterfaceFilter_Networks(t *testing.T) {
+
+	// Find the loopback interface.
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lo *net.Interface
+	for _, ifi := range ifis {
+		if ifi.Flags&net.FlagLoopback != 0 {
+			lo = &ifi
+			break
+		}
+	}
+	if lo == nil {
+		t.Skip("No loopback interface available")
+	}
+
+	// Loopback should be within 127.0.0.0/8 but not 10.0.0.0/8.
+	_, loNet, _ := net.ParseCIDR("127.0.0.0/8")
+	_, privNet, _ := net.ParseCIDR("10.0.0.0/8")
+	if !(&InterfaceFilter{Networks: []*net.IPNet{loNet}}).Match(lo) {
+		t.Fatal("Expected loopback to match")
+	}
+	if (&InterfaceFilter{Networks: []*net.IPNet{privNet}}).Match(lo) {
+		t.Fatal("Expected loopback not to match")
+	}
+}
@@ -0,0 +1,192 @@
+package comm
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// LocalInterface is the name of the interface reported by LocalTransport.
+const LocalInterface = "unix"
+
+// Time allowed for writing to each socket. Packets are dropped for receivers
+// that are not keeping up rather than delaying the sender.
+const localWriteTimeout = 5 * time.Millisecond
+
+// LocalTransport exchanges packets with other processes on the same machine
+// using Unix datagram sockets. Each transport creates a socket in a shared
+// rendezvous directory and sends packets to every other socket in it. This
+// works even when no network interfaces are available. Received packets are
+// reported as coming from the IPv4 loopback address.
+type LocalTransport struct {
+	packetChan chan *Packet
+	eventChan  chan InterfaceEvent
+	stopChan   chan interface{}
+	waitGroup  sync.WaitGroup
+	conn       *net.UnixConn
+	dir        string
+	path       string
+}
+
+// Obtain the default rendezvous directory for the specified port so that
+// services using different ports do not see each other. The directory is
+// private to the current user, within $XDG_RUNTIME_DIR if it is set.
+func DefaultLocalDir(port int) string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, fmt.Sprintf("sdiscovery-%d", port))
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("sdiscovery-%d-%d", os.Getuid(), port))
+}
+
+// Create a new transport using the specified rendezvous directory, which is
+// created if it does not exist. An existing directory is rejected unless it
+// is owned by the current user with mode 0700, since anyone able to write to
+// it could inject or remove sockets.
+func NewLocalTransport(dir string) (*LocalTransport, error) {
+
+	// Ensure that the directory exists and is safe to use.
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := checkLocalDir(dir); err != nil {
+		return nil, err
+	}
+
+	// Create a socket with a name that is unique to this process.
+	path := filepath.Join(dir, fmt.Sprintf("%d-%d.sock", os.Getpid(), rand.Int63()))
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{
+		Name: path,
+		Net:  "unixgram",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l := &LocalTransport{
+		packetChan: make(chan *Packet),
+		eventChan:  make(chan InterfaceEvent),
+		stopChan:   make(chan interface{}),
+		conn:       conn,
+		dir:        dir,
+		path:       path,
+	}
+
+	// Spawn goroutines to read from the socket and announce the interface.
+	l.waitGroup.Add(2)
+	go l.run()
+	go l.announce()
+
+	// Close the channels once both goroutines have finished.
+	go func() {
+		l.waitGroup.Wait()
+		close(l.packetChan)
+		close(l.eventChan)
+	}()
+
+	return l, nil
+}
+
+// Send the event indicating that the interface was added.
+func (l *LocalTransport) announce() {
+	defer l.waitGroup.Done()
+	select {
+	case l.eventChan <- InterfaceEvent{Name: LocalInterface, Added: true}:
+	case <-l.stopChan:
+	}
+}
+
+// Continuously read packets from the socket.
+func (l *LocalTransport) run() {
+	defer l.waitGroup.Done()
+	for {
+
+		// Use the same cap on packet size as network connections.
+		b := make([]byte, 1000)
+
+		// Read the packet, quitting on error.
+		n, _, err := l.conn.ReadFromUnix(b)
+		if err != nil {
+			return
+		}
+
+		// Attempt to create the packet.
+		pkt, err := NewPacketFromJSON(net.IPv4(127, 0, 0, 1), b[:n])
+		if err != nil {
+			continue
+		}
+		pkt.Interface = LocalInterface
+		pkt.Type = LocalInterface
+
+		// Write the packet to the channel.
+		select {
+		case l.packetChan <- pkt:
+		case <-l.stopChan:
+			return
+		}
+	}
+}
+
+// Send a packet to every other socket in the rendezvous directory. Sockets
+// that refuse the packet belong to processes that have exited and are
+// removed. If a socket's buffer is full, the packet is dropped for it.
+func (l *LocalTransport) Send(pkt *Packet) {
+
+	// Convert the packet to JSON.
+	data, err := pkt.ToJSON()
+	if err != nil {
+		return
+	}
+
+	// Find all of the sockets in the directory.
+	paths, err := filepath.Glob(filepath.Join(l.dir, "*.sock"))
+	if err != nil {
+		return
+	}
+
+	for _, path := range paths {
+		if path == l.path {
+			continue
+		}
+		if err := l.conn.SetWriteDeadline(time.Now().Add(localWriteTimeout)); err != nil {
+			return
+		}
+		if _, err := l.conn.WriteToUnix(data, &net.UnixAddr{
+			Name: path,
+			Net:  "unixgram",
+		}); errors.Is(err, syscall.ECONNREFUSED) {
+			os.Remove(path)
+		}
+	}
+}
+
+// Obtain the channel on which received packets are sent.
+func (l *LocalTransport) Receive() <-chan *Packet {
+	return l.packetChan
+}
+
+// Obtain the channel on which interface additions and removals are sent.
+func (l *LocalTransport) Events() <-chan InterfaceEvent {
+	return l.eventChan
+}
+
+// Describe the socket used by the transport.
+func (l *LocalTransport) Connections() []ConnectionInfo {
+	return []ConnectionInfo{{
+		Interface: LocalInterface,
+		Type:      LocalInterface,
+		Addr:      l.dir,
+	}}
+}
+
+// Close the socket and remove it from the rendezvous directory.
+func (l *LocalTransport) Close() {
+	l.conn.Close()
+	os.Remove(l.path)
+	close(l.stopChan)
+}
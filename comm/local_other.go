@@ -0,0 +1,22 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package comm
+
+import (
+	"errors"
+	"os"
+)
+
+// Ownership and permissions cannot be checked on this platform, so only
+// ensure that the rendezvous path is a directory.
+func checkLocalDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return errors.New("Rendezvous path is not a directory")
+	}
+	return nil
+}
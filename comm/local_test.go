@@ -0,0 +1,131 @@
+package comm
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Attempt to receive a packet from the channel.
+func receivePacket(c <-chan *Packet) *Packet {
+	select {
+	case pkt := <-c:
+		return pkt
+	case <-time.After(100 * time.Millisecond):
+		return nil
+	}
+}
+
+// Create a temporary rendezvous directory that only the current user can
+// access.
+func testLocalDir(t *testing.T) string {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// Create a local transport in the directory, skipping the test if Unix
+// sockets are not supported.
+func newTestLocalTransport(t *testing.T, dir string) *LocalTransport {
+	l, err := NewLocalTransport(dir)
+	if err != nil {
+		t.Skip(err)
+	}
+	return l
+}
+
+// Ensure that packets are exchanged between local transports.
+func Test_LocalTransport(t *testing.T) {
+
+	dir := testLocalDir(t)
+	a := newTestLocalTransport(t, dir)
+	defer a.Close()
+	b := newTestLocalTransport(t, dir)
+	defer b.Close()
+
+	// Ensure the interface event is sent.
+	if e := <-a.Events(); e.Name != LocalInterface || !e.Added {
+		t.Fatal("Expected interface to be added")
+	}
+
+	// Node b should receive the packet from the loopback address.
+	a.Send(&Packet{ID: "a"})
+	pkt := receivePacket(b.Receive())
+	if pkt == nil {
+		t.Fatal("Packet was not delivered")
+	}
+	if pkt.ID != "a" || pkt.Interface != LocalInterface || !pkt.IP.IsLoopback() {
+		t.Fatal("Packet contents are incorrect")
+	}
+
+	// Node a should not receive its own packet.
+	if receivePacket(a.Receive()) != nil {
+		t.Fatal("Packet delivered to sender")
+	}
+}
+
+// Ensure that sockets left behind by exited processes are removed.
+func Test_LocalTransport_Stale(t *testing.T) {
+
+	dir := testLocalDir(t)
+	a := newTestLocalTransport(t, dir)
+	defer a.Close()
+
+	// Create a socket and close it, which leaves the file behind.
+	path := filepath.Join(dir, "stale.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// Sending should remove the socket.
+	a.Send(&Packet{ID: "a"})
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("Stale socket was not removed")
+	}
+}
+
+// Ensure that a rendezvous directory accessible to other users is rejected.
+func Test_NewLocalTransport_unsafe(t *testing.T) {
+
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := NewLocalTransport(dir); err == nil {
+		l.Close()
+		t.Fatal("Expected directory to be rejected")
+	}
+}
+
+// Ensure that a receiver that never reads does not delay the sender.
+func Test_LocalTransport_Full(t *testing.T) {
+
+	dir := testLocalDir(t)
+	a := newTestLocalTransport(t, dir)
+	defer a.Close()
+
+	// Create a socket that is never read from.
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{
+		Name: filepath.Join(dir, "full.sock"),
+		Net:  "unixgram",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Sending should continue promptly once the socket's buffer is full.
+	start := time.Now()
+	for i := 0; i < 50; i++ {
+		a.Send(&Packet{ID: "a"})
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("Sending was delayed by a full socket")
+	}
+}
@@ -0,0 +1,30 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package comm
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Ensure that the rendezvous directory cannot be used by other users to
+// inject or remove sockets. It must be a directory (not a symlink) owned by
+// the current user and accessible only by them.
+func checkLocalDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return errors.New("Rendezvous path is not a directory")
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return errors.New("Rendezvous directory is owned by another user")
+	}
+	if fi.Mode().Perm() != 0700 {
+		return errors.New("Rendezvous directory must have mode 0700")
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package comm
+
+import (
+	"errors"
+	"net"
+)
+
+// Mode determines which types of packets are sent and received on a network
+// interface. Modes may be combined using bitwise OR.
+type Mode int
+
+const (
+	ModeMulticastIPv6 Mode = 1 << iota // IPv6 multicast
+	ModeBroadcastIPv4                  // IPv4 subnet broadcast
+	ModeMulticastIPv4                  // IPv4 multicast
+
+	// ModeDefault is used when no mode is specified.
+	ModeDefault = ModeMulticastIPv6 | ModeBroadcastIPv4
+
+	// ModeDisabled prevents an interface listed in InterfaceModes from
+	// being used.
+	ModeDisabled Mode = 0
+)
+
+// MulticastScope is the scope of an IPv6 multicast group as defined in
+// RFC 4291. The values correspond to the scope field of the address.
+type MulticastScope int
+
+const (
+	ScopeDefault           MulticastScope = 0x0 // use the scope of the group address
+	ScopeInterfaceLocal    MulticastScope = 0x1 // local machine only
+	ScopeLinkLocal         MulticastScope = 0x2 // single network segment
+	ScopeAdminLocal        MulticastScope = 0x4 // administratively configured
+	ScopeSiteLocal         MulticastScope = 0x5 // single site
+	ScopeOrganizationLocal MulticastScope = 0x8 // multiple sites
+	ScopeGlobal            MulticastScope = 0xe // entire internet
+)
+
+var (
+	// DefaultGroupIPv4 is the IPv4 multicast group used when none is
+	// specified. It is within the administratively scoped 239.255.0.0/16
+	// block.
+	DefaultGroupIPv4 = net.IPv4(239, 255, 83, 68)
+
+	// DefaultGroupIPv6 is the IPv6 multicast group used when none is
+	// specified. Note that every IPv6 host processes packets sent to this
+	// group, so a dedicated group is preferable where compatibility with
+	// older peers is not required.
+	DefaultGroupIPv6 = net.IPv6linklocalallnodes
+)
+
+// MulticastConfig controls the behavior of multicast connections. If a group
+// address is nil, DefaultGroupIPv4 or DefaultGroupIPv6 is used. If Scope is
+// set, it replaces the scope of the IPv6 group address. If TTL or HopLimit is
+// zero, packets are limited to the local network. Loopback determines whether
+// packets sent are also delivered to sockets on the local machine.
+//
+// Crossing routers requires both a group with a wide enough scope and a TTL
+// or hop limit greater than the number of routers to be crossed.
+type MulticastConfig struct {
+	GroupIPv4 net.IP         // IPv4 multicast group address
+	GroupIPv6 net.IP         // IPv6 multicast group address
+	TTL       int            // time-to-live for IPv4 multicast packets
+	HopLimit  int            // hop limit for IPv6 multicast packets
+	Scope     MulticastScope // scope for the IPv6 group address
+	Loopback  bool           // deliver packets to the local machine
+}
+
+// Obtain the IPv4 group address, falling back to the default.
+func (m *MulticastConfig) groupIPv4() (net.IP, error) {
+	ip := m.GroupIPv4
+	if ip == nil {
+		ip = DefaultGroupIPv4
+	}
+	if ip.To4() == nil || !ip.IsMulticast() {
+		return nil, errors.New("Not an IPv4 multicast address")
+	}
+	return ip, nil
+}
+
+// Obtain the IPv6 group address, falling back to the default and applying
+// the scope.
+func (m *MulticastConfig) groupIPv6() (net.IP, error) {
+	ip := m.GroupIPv6
+	if ip == nil {
+		ip = DefaultGroupIPv6
+	}
+	if ip.To4() != nil || !ip.IsMulticast() {
+		return nil, errors.New("Not an IPv6 multicast address")
+	}
+	if m.Scope != ScopeDefault {
+		ip = append(net.IP{}, ip...)
+		ip[1] = ip[1]&0xf0 | byte(m.Scope)&0x0f
+	}
+	return ip, nil
+}
+
+// Obtain the TTL, falling back to the default.
+func (m *MulticastConfig) ttl() int {
+	if m.TTL == 0 {
+		return 1
+	}
+	return m.TTL
+}
+
+// Obtain the hop limit, falling back to the default.
+func (m *MulticastConfig) hopLimit() int {
+	if m.HopLimit == 0 {
+		return 1
+	}
+	return m.HopLimit
+}
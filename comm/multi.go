@@ -0,0 +1,108 @@
+package comm
+
+import (
+	"sync"
+)
+
+// MultiTransport combines several transports into one. Packets are sent using
+// each of the transports and packets and events received from all of them
+// are merged.
+type MultiTransport struct {
+	transports []Transport
+	packetChan chan *Packet
+	eventChan  chan InterfaceEvent
+	stopChan   chan interface{}
+}
+
+// Create a new transport that combines the specified transports. Closing the
+// new transport closes each of them.
+func NewMultiTransport(transports ...Transport) *MultiTransport {
+
+	m := &MultiTransport{
+		transports: transports,
+		packetChan: make(chan *Packet),
+		eventChan:  make(chan InterfaceEvent),
+		stopChan:   make(chan interface{}),
+	}
+
+	// Spawn goroutines to forward packets and events from each transport.
+	var waitGroup sync.WaitGroup
+	for _, t := range transports {
+		waitGroup.Add(2)
+		go func(t Transport) {
+			defer waitGroup.Done()
+			m.forwardPackets(t.Receive())
+		}(t)
+		go func(t Transport) {
+			defer waitGroup.Done()
+			m.forwardEvents(t.Events())
+		}(t)
+	}
+
+	// Close the channels once every transport has finished.
+	go func() {
+		waitGroup.Wait()
+		close(m.packetChan)
+		close(m.eventChan)
+	}()
+
+	return m
+}
+
+// Forward packets until the channel is closed. Once stopped, packets continue
+// to be drained but are discarded.
+func (m *MultiTransport) forwardPackets(packetChan <-chan *Packet) {
+	for pkt := range packetChan {
+		select {
+		case m.packetChan <- pkt:
+		case <-m.stopChan:
+		}
+	}
+}
+
+// Forward events until the channel is closed. Once stopped, events continue
+// to be drained but are discarded.
+func (m *MultiTransport) forwardEvents(eventChan <-chan InterfaceEvent) {
+	for e := range eventChan {
+		select {
+		case m.eventChan <- e:
+		case <-m.stopChan:
+		}
+	}
+}
+
+// Send the packet using each of the transports.
+func (m *MultiTransport) Send(pkt *Packet) {
+	for _, t := range m.transports {
+		t.Send(pkt)
+	}
+}
+
+// Obtain the channel on which received packets are sent.
+func (m *MultiTransport) Receive() <-chan *Packet {
+	return m.packetChan
+}
+
+// Obtain the channel on which interface additions and removals are sent.
+func (m *MultiTransport) Events() <-chan InterfaceEvent {
+	return m.eventChan
+}
+
+// Obtain the connections of each transport that can describe them.
+func (m *MultiTransport) Connections() []ConnectionInfo {
+	var infos []ConnectionInfo
+	for _, t := range m.transports {
+		if l, ok := t.(ConnectionLister); ok {
+			infos = append(infos, l.Connections()...)
+		}
+	}
+	return infos
+}
+
+// Close each of the transports.
+func (m *MultiTransport) Close() {
+	close(m.stopChan)
+	for _, t := range m.transports {
+		t.Close()
+	}
+}
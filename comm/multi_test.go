@@ -0,0 +1,44 @@
+package comm
+
+import (
+	"testing"
+)
+
+// Ensure that packets and events from each transport are merged.
+func Test_MultiTransport(t *testing.T) {
+
+	dir1, dir2 := testLocalDir(t), testLocalDir(t)
+	a1 := newTestLocalTransport(t, dir1)
+	a2 := newTestLocalTransport(t, dir2)
+	m := NewMultiTransport(a1, a2)
+	b1 := newTestLocalTransport(t, dir1)
+	defer b1.Close()
+	b2 := newTestLocalTransport(t, dir2)
+	defer b2.Close()
+
+	// An event should be received for each transport.
+	for i := 0; i < 2; i++ {
+		<-m.Events()
+	}
+
+	// Packets from both should be received.
+	b1.Send(&Packet{ID: "b1"})
+	if pkt := receivePacket(m.Receive()); pkt == nil || pkt.ID != "b1" {
+		t.Fatal("Packet from first transport not received")
+	}
+	b2.Send(&Packet{ID: "b2"})
+	if pkt := receivePacket(m.Receive()); pkt == nil || pkt.ID != "b2" {
+		t.Fatal("Packet from second transport not received")
+	}
+
+	// Packets sent should go to both.
+	m.Send(&Packet{ID: "m"})
+	if receivePacket(b1.Receive()) == nil || receivePacket(b2.Receive()) == nil {
+		t.Fatal("Packet was not sent on each transport")
+	}
+
+	// Closing should close the merged channels.
+	m.Close()
+	for range m.Receive() {
+	}
+}
@@ -7,9 +7,12 @@ import (
 
 // Packet represents an individual packet received from a network interface.
 type Packet struct {
-	IP       net.IP `json:"-"`         // IP address from which the packet was obtained
-	ID       string `json:"id"`        // ID of the peer that sent the packet
-	UserData []byte `json:"user_data"` // custom data provided by the peer
+	IP         net.IP            `json:"-"`                    // IP address from which the packet was obtained
+	Interface  string            `json:"-"`                    // name of the interface that received the packet
+	Type       string            `json:"-"`                    // type of connection that received the packet
+	ID         string            `json:"id"`                   // ID of the peer that sent the packet
+	UserData   []byte            `json:"user_data"`            // custom data provided by the peer
+	Attributes map[string]string `json:"attributes,omitempty"` // key/value attributes provided by the peer
 }
 
 // Create a new packet using the specified IP address and JSON data.
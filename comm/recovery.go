@@ -0,0 +1,118 @@
+package comm
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetryInterval    = time.Second
+	defaultMaxRetryInterval = time.Minute
+)
+
+// retry describes a connection that failed and is waiting to be recreated.
+type retry struct {
+	name    string
+	pType   packetType
+	attempt int
+	since   time.Time
+}
+
+// Determine how long to wait before the specified retry attempt. The delay
+// doubles with each attempt until it reaches MaxRetryInterval.
+func (c *CommunicatorConfig) retryDelay(attempt int) time.Duration {
+	delay, max := c.RetryInterval, c.MaxRetryInterval
+	if delay <= 0 {
+		delay = defaultRetryInterval
+	}
+	if max <= 0 {
+		max = defaultMaxRetryInterval
+	}
+	for i := 0; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// Schedule an attempt to recreate a connection. When the delay elapses, the
+// retry is sent to retryChan unless ctx is cancelled first.
+func (c *Communicator) scheduleRetry(ctx context.Context, r *retry) {
+
+	// Keep track of the retry so that it can be cancelled if the interface
+	// is removed.
+	if c.retries[r.name] == nil {
+		c.retries[r.name] = make(map[packetType]*retry)
+	}
+	c.retries[r.name][r.pType] = r
+
+	delay := c.config.retryDelay(r.attempt)
+	c.config.Logger.Warn("connection failed, retrying", "interface", r.name, "type", r.pType, "delay", delay)
+	go func() {
+		select {
+		case <-c.config.Clock.After(delay):
+			select {
+			case c.retryChan <- r:
+			case <-ctx.Done():
+			}
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// Remove a connection whose socket failed and schedule it to be recreated.
+func (c *Communicator) removeDeadConnection(ctx context.Context, conn *connection) {
+
+	// Ignore the connection if it was already removed, since the interface
+	// was removed or changed while the notification was pending.
+	connections := c.connections[conn.name]
+	for i, v := range connections {
+		if v == conn {
+			c.connections[conn.name] = append(connections[:i:i], connections[i+1:]...)
+			conn.stop()
+			c.scheduleRetry(ctx, &retry{
+				name:  conn.name,
+				pType: conn.pType,
+				since: c.config.Clock.Now(),
+			})
+			return
+		}
+	}
+}
+
+// Attempt to recreate a connection, scheduling another attempt on failure.
+func (c *Communicator) retryConnection(ctx context.Context, r *retry, waitGroup *sync.WaitGroup) {
+
+	// Ignore retries that were cancelled or replaced.
+	if c.retries[r.name][r.pType] != r {
+		return
+	}
+	delete(c.retries[r.name], r.pType)
+
+	// Attempt to recreate the connection.
+	ifi, err := net.InterfaceByName(r.name)
+	if err == nil {
+		var conn *connection
+		conn, err = newConnection(c.PacketChan, c.deadChan, waitGroup, ifi, &c.config, r.pType)
+		if err == nil {
+			c.connections[r.name] = append(c.connections[r.name], conn)
+			c.config.Logger.Info("connection recovered", "interface", r.name, "type", r.pType,
+				"outage", c.config.Clock.Now().Sub(r.since))
+			return
+		}
+	}
+
+	// Report the failure and try again later.
+	c.config.reportError(&Error{Kind: ErrSocket, Interface: r.name, Err: err})
+	r.attempt++
+	c.scheduleRetry(ctx, r)
+}
+
+// Cancel any pending retries for the specified interface.
+func (c *Communicator) cancelRetries(name string) {
+	delete(c.retries, name)
+}
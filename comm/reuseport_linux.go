@@ -0,0 +1,15 @@
+package comm
+
+import (
+	"runtime"
+)
+
+// SO_REUSEPORT is missing from the syscall package on most Linux platforms.
+// The value differs on MIPS.
+var soReusePort = func() int {
+	switch runtime.GOARCH {
+	case "mips", "mipsle", "mips64", "mips64le":
+		return 0x200
+	}
+	return 0xf
+}()
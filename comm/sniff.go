@@ -0,0 +1,32 @@
+package comm
+
+import (
+	"net"
+	"time"
+)
+
+// Largest datagram that will be decoded. Larger datagrams are dropped.
+const maxPacketSize = 1000
+
+// Reasons that a datagram is dropped by a connection.
+const (
+	DropSize      = "size"      // the datagram exceeds the maximum size
+	DropInterface = "interface" // the sender is not on the interface's networks
+	DropDecode    = "decode"    // the datagram is not a valid packet
+)
+
+// Datagram describes a single datagram received by a connection along with
+// the result of decoding it. Packet is nil if the datagram could not be
+// decoded, in which case Err describes why. Dropped is empty if the packet
+// was passed on to the service and one of the Drop constants otherwise.
+type Datagram struct {
+	Time      time.Time    // when the datagram was received
+	Interface string       // name of the interface that received it
+	Type      string       // type of connection that received it
+	Addr      *net.UDPAddr // address of the sender
+	Dest      *net.UDPAddr // broadcast or multicast address it was sent to
+	Data      []byte       // contents of the datagram
+	Packet    *Packet      // decoded packet (if valid)
+	Err       error        // decoding error (if any)
+	Dropped   string       // reason the datagram was dropped (if any)
+}
@@ -0,0 +1,10 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package comm
+
+// Multicast datagrams are only delivered to sockets that joined the group on
+// this platform, so nothing needs to be done.
+func restrictMulticast(fd int) error {
+	return nil
+}
@@ -0,0 +1,15 @@
+package comm
+
+import (
+	"syscall"
+)
+
+// IP_MULTICAST_ALL is missing from the syscall package.
+const ipMulticastAll = 0x31
+
+// Prevent a socket bound to the wildcard address from receiving multicast
+// datagrams for groups joined by other sockets, which Linux delivers to every
+// matching socket by default.
+func restrictMulticast(fd int) error {
+	return syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, ipMulticastAll, 0)
+}
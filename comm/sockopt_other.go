@@ -0,0 +1,44 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package comm
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// MulticastOptionsSupported indicates whether the TTL, hop limit and loopback
+// options in MulticastConfig can be applied on this platform.
+const MulticastOptionsSupported = false
+
+// Address reuse cannot be enabled on this platform, so only a single process
+// can use each port.
+func controlReuse(network, address string, rawConn syscall.RawConn) error {
+	return nil
+}
+
+// Socket options cannot be set on this platform, so broadcast connections are
+// created with the defaults.
+func controlBroadcast(network, address string, rawConn syscall.RawConn) error {
+	return nil
+}
+
+// Socket options cannot be set on this platform, so fail unless the defaults
+// were requested.
+func setMulticastOptionsIPv4(conn *net.UDPConn, ttl int, loopback bool) error {
+	if ttl != 1 || loopback {
+		return errors.New("Multicast options are not supported on this platform")
+	}
+	return nil
+}
+
+// Socket options cannot be set on this platform, so fail unless the defaults
+// were requested.
+func setMulticastOptionsIPv6(conn *net.UDPConn, hopLimit int, loopback bool) error {
+	if hopLimit != 1 || loopback {
+		return errors.New("Multicast options are not supported on this platform")
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package comm
+
+import (
+	"net"
+	"syscall"
+)
+
+// MulticastOptionsSupported indicates whether the TTL, hop limit and loopback
+// options in MulticastConfig can be applied on this platform.
+const MulticastOptionsSupported = true
+
+// Allow multiple sockets to bind to the same address and port so that more
+// than one process on the same machine can use the same port and so that the
+// port can be reused immediately after a restart. This is intended for use
+// as the Control function of a net.ListenConfig.
+func controlReuse(network, address string, rawConn syscall.RawConn) error {
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+		if sockErr == nil {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+		}
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// Allow address reuse as with controlReuse and also prevent the socket from
+// receiving IPv4 multicast datagrams, which would otherwise be processed a
+// second time when IPv4 multicast is also in use. This is intended for use as
+// the Control function of a net.ListenConfig for broadcast connections.
+func controlBroadcast(network, address string, rawConn syscall.RawConn) error {
+	if err := controlReuse(network, address, rawConn); err != nil {
+		return err
+	}
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = restrictMulticast(int(fd))
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// Set the multicast TTL and loopback options on an IPv4 connection.
+func setMulticastOptionsIPv4(conn *net.UDPConn, ttl int, loopback bool) error {
+
+	// Obtain access to the underlying socket.
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	// Both options are set as single bytes, which all platforms accept.
+	var loop byte
+	if loopback {
+		loop = 1
+	}
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptByte(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, byte(ttl))
+		if sockErr == nil {
+			sockErr = syscall.SetsockoptByte(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_LOOP, loop)
+		}
+	}); err != nil {
+		return err
+	}
+
+	return sockErr
+}
+
+// Set the multicast hop limit and loopback options on an IPv6 connection.
+func setMulticastOptionsIPv6(conn *net.UDPConn, hopLimit int, loopback bool) error {
+
+	// Obtain access to the underlying socket.
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	// Unlike IPv4, both options are set as integers on all platforms.
+	loop := 0
+	if loopback {
+		loop = 1
+	}
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_HOPS, hopLimit)
+		if sockErr == nil {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_LOOP, loop)
+		}
+	}); err != nil {
+		return err
+	}
+
+	return sockErr
+}
@@ -0,0 +1,35 @@
+package comm
+
+// InterfaceEvent indicates that a transport has started or stopped using a
+// network interface.
+type InterfaceEvent struct {
+	Name  string // name of the interface
+	Added bool   // true if the interface was added, false if removed
+}
+
+// Transport sends and receives packets on behalf of a service. Communicator is
+// the default implementation, using UDP broadcast and multicast, but any
+// mechanism capable of delivering packets to other peers may be used.
+//
+// The channels returned by Receive() and Events() must be drained by the
+// consumer and are closed once the transport has finished shutting down after
+// a call to Close(). Send() must not be called after Close().
+type Transport interface {
+	Send(pkt *Packet)              // send a packet to all peers
+	Receive() <-chan *Packet       // packets received from other peers
+	Events() <-chan InterfaceEvent // interface additions and removals
+	Close()                        // stop sending and receiving packets
+}
+
+// ConnectionInfo describes a single connection used by a transport.
+type ConnectionInfo struct {
+	Interface string `json:"interface"` // name of the interface
+	Type      string `json:"type"`      // type of packets sent and received
+	Addr      string `json:"addr"`      // address to which packets are sent
+}
+
+// ConnectionLister is implemented by transports that can describe the
+// connections they are currently using.
+type ConnectionLister interface {
+	Connections() []ConnectionInfo
+}
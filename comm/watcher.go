@@ -0,0 +1,25 @@
+package comm
+
+import (
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// interfaceWatcher signals when network interfaces may have changed and need
+// to be enumerated again. Platforms that can be notified of changes do so
+// immediately while others rely on polling.
+type interfaceWatcher interface {
+	C() <-chan time.Time // signals that interfaces should be enumerated
+	Stop()               // stop watching for changes
+}
+
+// pollingWatcher signals at regular intervals.
+type pollingWatcher struct {
+	util.Ticker
+}
+
+// Create a watcher that signals each time the poll interval elapses.
+func newPollingWatcher(clock util.Clock, pollInterval time.Duration) interfaceWatcher {
+	return pollingWatcher{clock.NewTicker(pollInterval)}
+}
@@ -0,0 +1,134 @@
+package comm
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Multicast groups from linux/rtnetlink.h, which the syscall package lacks.
+const (
+	rtmgrpLink       = 0x1
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv6IfAddr = 0x100
+)
+
+// netlinkWatcher signals when rtnetlink reports a change to a link or address
+// in addition to polling at regular intervals.
+type netlinkWatcher struct {
+	poller   interfaceWatcher
+	file     *os.File
+	c        chan time.Time
+	stopChan chan interface{}
+	logger   util.Logger
+}
+
+// Open a netlink socket subscribed to link and address changes.
+func openNetlink() (*os.File, error) {
+
+	// Create a non-blocking socket so that the runtime poller is used for
+	// reading and closing the file interrupts a pending read.
+	fd, err := syscall.Socket(
+		syscall.AF_NETLINK,
+		syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK,
+		syscall.NETLINK_ROUTE,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Subscribe to the multicast groups for link and address changes.
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpLink | rtmgrpIPv4IfAddr | rtmgrpIPv6IfAddr,
+	}); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(fd), "netlink"), nil
+}
+
+// Create a watcher for interface changes. If a netlink socket cannot be
+// opened, interfaces are only polled.
+func newInterfaceWatcher(clock util.Clock, pollInterval time.Duration, logger util.Logger) interfaceWatcher {
+
+	// Attempt to open the socket, falling back to polling.
+	file, err := openNetlink()
+	if err != nil {
+		logger.Debug("unable to open netlink socket", "error", err)
+		return newPollingWatcher(clock, pollInterval)
+	}
+
+	// Create the watcher. Polling continues in case notifications are lost.
+	w := &netlinkWatcher{
+		poller:   newPollingWatcher(clock, pollInterval),
+		file:     file,
+		c:        make(chan time.Time, 1),
+		stopChan: make(chan interface{}),
+		logger:   logger,
+	}
+
+	// Spawn goroutines to read notifications and forward ticks.
+	go w.read(clock)
+	go w.poll()
+
+	return w
+}
+
+// Signal without blocking. Multiple signals are coalesced into one.
+func (w *netlinkWatcher) signal(t time.Time) {
+	select {
+	case w.c <- t:
+	default:
+	}
+}
+
+// Read messages from the socket until it is closed. The contents of the
+// messages are irrelevant since interfaces are enumerated again anyway. If
+// the socket buffer overflows, messages were lost, which is also a change.
+func (w *netlinkWatcher) read(clock util.Clock) {
+	b := make([]byte, os.Getpagesize())
+	for {
+		if _, err := w.file.Read(b); err != nil {
+			if errors.Is(err, syscall.ENOBUFS) {
+				w.signal(clock.Now())
+				continue
+			}
+			select {
+			case <-w.stopChan:
+			default:
+				w.logger.Warn("unable to read from netlink socket, polling only", "error", err)
+			}
+			return
+		}
+		w.signal(clock.Now())
+	}
+}
+
+// Forward ticks from the poller until stopped.
+func (w *netlinkWatcher) poll() {
+	for {
+		select {
+		case t := <-w.poller.C():
+			w.signal(t)
+		case <-w.stopChan:
+			return
+		}
+	}
+}
+
+// Obtain the channel on which signals are sent.
+func (w *netlinkWatcher) C() <-chan time.Time {
+	return w.c
+}
+
+// Close the socket and stop polling.
+func (w *netlinkWatcher) Stop() {
+	close(w.stopChan)
+	w.file.Close()
+	w.poller.Stop()
+}
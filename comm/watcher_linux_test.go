@@ -0,0 +1,43 @@
+package comm
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Ensure that each message causes a signal and that an unexpected error is
+// logged when the watcher stops reading.
+func Test_netlinkWatcher_read(t *testing.T) {
+
+	r, wr, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	w := &netlinkWatcher{
+		file:     r,
+		c:        make(chan time.Time, 1),
+		stopChan: make(chan interface{}),
+		logger:   util.NewSlogLogger(slog.NewTextHandler(&buf, nil)),
+	}
+
+	// Write a message and then close the pipe so that the next read fails.
+	wr.Write([]byte("test"))
+	wr.Close()
+	w.read(util.NewFakeClock(time.Unix(0, 0)))
+	select {
+	case <-w.C():
+	default:
+		t.Fatal("Watcher did not signal")
+	}
+	if !strings.Contains(buf.String(), "polling only") {
+		t.Fatal("Error was not logged")
+	}
+}
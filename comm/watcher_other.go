@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package comm
+
+import (
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Create a watcher for interface changes. No notification mechanism is
+// available on this platform so interfaces are polled.
+func newInterfaceWatcher(clock util.Clock, pollInterval time.Duration, logger util.Logger) interfaceWatcher {
+	return newPollingWatcher(clock, pollInterval)
+}
@@ -0,0 +1,25 @@
+package comm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Ensure that the watcher signals when the poll interval elapses and can be
+// stopped.
+func Test_interfaceWatcher(t *testing.T) {
+
+	c := util.NewFakeClock(time.Unix(0, 0))
+	w := newInterfaceWatcher(c, time.Minute, util.DiscardLogger)
+	defer w.Stop()
+
+	// Advancing the clock should cause a signal.
+	c.Advance(time.Minute)
+	select {
+	case <-w.C():
+	case <-time.After(time.Second):
+		t.Fatal("Watcher did not signal")
+	}
+}
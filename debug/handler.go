@@ -0,0 +1,175 @@
+package debug
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery"
+	"github.com/nathan-osman/go-sdiscovery/comm"
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Config determines what the handler displays. Service is required. Events
+// are only displayed if Recorder is set, in which case it must also be used
+// as (or invoked by) the Hook of the service. If Clock is nil,
+// util.SystemClock is used for calculating ages.
+type Config struct {
+	Service  *sdiscovery.Service // service to inspect
+	Recorder *Recorder           // source of recent events
+	Clock    util.Clock          // source of the current time
+}
+
+// Addr describes a single address of a peer.
+type Addr struct {
+	IP        string    `json:"ip"`
+	Interface string    `json:"interface"`
+	LastSeen  time.Time `json:"last_seen"`
+	Pings     int       `json:"pings"`
+	Span      float64   `json:"span_seconds"`
+}
+
+// Peer describes a single peer.
+type Peer struct {
+	ID         string            `json:"id"`
+	UserData   []byte            `json:"user_data"`
+	Attributes map[string]string `json:"attributes"`
+	Addrs      []Addr            `json:"addrs"`
+	Age        float64           `json:"age_seconds"`
+	Stale      bool              `json:"stale"`
+}
+
+// Event describes a single recent event.
+type Event struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	PeerID    string    `json:"peer_id,omitempty"`
+	Interface string    `json:"interface,omitempty"`
+	Addr      string    `json:"addr,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Status is the complete state displayed by the handler.
+type Status struct {
+	Time        time.Time             `json:"time"`
+	Peers       []Peer                `json:"peers"`
+	Interfaces  []string              `json:"interfaces"`
+	Connections []comm.ConnectionInfo `json:"connections"`
+	Events      []Event               `json:"events"`
+}
+
+// Handler renders the state of a service as HTML or JSON. JSON is returned if
+// the format query parameter is "json" or the request accepts
+// application/json.
+type Handler struct {
+	config Config
+}
+
+// Create a new handler with the specified configuration.
+func NewHandler(config Config) *Handler {
+	if config.Clock == nil {
+		config.Clock = util.SystemClock
+	}
+	return &Handler{
+		config: config,
+	}
+}
+
+// Collect the current state of the service.
+func (h *Handler) Status() *Status {
+
+	now := h.config.Clock.Now()
+	status := &Status{
+		Time:        now,
+		Peers:       []Peer{},
+		Interfaces:  h.config.Service.Interfaces(),
+		Connections: h.config.Service.Connections(),
+		Events:      []Event{},
+	}
+	if status.Connections == nil {
+		status.Connections = []comm.ConnectionInfo{}
+	}
+
+	// Describe each of the peers and their addresses, best first.
+	for _, info := range h.config.Service.Peers() {
+		p := Peer{
+			ID:         info.ID,
+			UserData:   info.UserData,
+			Attributes: info.Attributes,
+			Addrs:      []Addr{},
+			Age:        now.Sub(info.LastSeen).Seconds(),
+			Stale:      info.Stale,
+		}
+		for _, addr := range info.Addrs {
+			p.Addrs = append(p.Addrs, Addr{
+				IP:        addr.IP.String(),
+				Interface: addr.Interface,
+				LastSeen:  addr.LastSeen,
+				Pings:     addr.Pings,
+				Span:      addr.Span.Seconds(),
+			})
+		}
+		status.Peers = append(status.Peers, p)
+	}
+
+	// Describe the recent events, newest first.
+	if h.config.Recorder != nil {
+		events := h.config.Recorder.Events()
+		for i := len(events) - 1; i >= 0; i-- {
+			e := events[i]
+			ev := Event{
+				Type:      e.Type.String(),
+				Time:      e.Time,
+				PeerID:    e.PeerID,
+				Interface: e.Interface,
+			}
+			if e.Addr != nil {
+				ev.Addr = e.Addr.String()
+			}
+			if e.Err != nil {
+				ev.Error = e.Err.Error()
+			}
+			status.Events = append(status.Events, ev)
+		}
+	}
+
+	return status
+}
+
+// Determine if the request is for JSON.
+func wantsJSON(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "json"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// Render the status of the service.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	// Render into a buffer so that an error can still be reported with the
+	// correct status code.
+	var (
+		b           bytes.Buffer
+		contentType string
+		err         error
+	)
+	status := h.Status()
+	if wantsJSON(r) {
+		contentType = "application/json"
+		e := json.NewEncoder(&b)
+		e.SetIndent("", "  ")
+		err = e.Encode(status)
+	} else {
+		contentType = "text/html; charset=utf-8"
+		err = statusTemplate.Execute(&b, status)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	b.WriteTo(w)
+}
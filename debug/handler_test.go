@@ -0,0 +1,98 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery"
+	"github.com/nathan-osman/go-sdiscovery/comm/commtest"
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Create a service that has discovered a single peer.
+func newTestService(t *testing.T, r *Recorder, c *util.FakeClock) (*sdiscovery.Service, func()) {
+	n := commtest.NewNetwork(0)
+	newService := func(id string, hook sdiscovery.Hook) *sdiscovery.Service {
+		return sdiscovery.New(sdiscovery.ServiceConfig{
+			PingInterval: time.Second,
+			PeerTimeout:  4 * time.Second,
+			ID:           id,
+			Attributes:   map[string]string{"role": id},
+			Transport:    n.NewNode(id, "eth0"),
+			Clock:        c,
+			Hook:         hook,
+		})
+	}
+	var hook sdiscovery.Hook
+	if r != nil {
+		hook = r
+	}
+	a := newService("a", hook)
+	b := newService("b", nil)
+	c.BlockUntil(4)
+	c.Advance(time.Second)
+	for a.PeerAdded != nil || b.PeerAdded != nil {
+		select {
+		case <-a.PeerAdded:
+			a.PeerAdded = nil
+		case <-b.PeerAdded:
+			b.PeerAdded = nil
+		case <-time.After(time.Second):
+			t.Fatal("Peers were not discovered")
+		}
+	}
+	return a, func() {
+		a.Stop()
+		b.Stop()
+	}
+}
+
+// Ensure that the status is rendered as JSON.
+func Test_Handler_JSON(t *testing.T) {
+
+	r := NewRecorder(0)
+	c := util.NewFakeClock(time.Unix(0, 0))
+	s, stop := newTestService(t, r, c)
+	defer stop()
+
+	w := httptest.NewRecorder()
+	NewHandler(Config{Service: s, Recorder: r, Clock: c}).ServeHTTP(w, httptest.NewRequest("GET", "/?format=json", nil))
+
+	var status Status
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Peers) != 1 || status.Peers[0].ID != "b" ||
+		status.Peers[0].Attributes["role"] != "b" || len(status.Peers[0].Addrs) != 1 {
+		t.Fatal("Peer is incorrect")
+	}
+	if status.Peers[0].Addrs[0].Interface != "eth0" || status.Peers[0].Addrs[0].Pings != 1 {
+		t.Fatal("Address is incorrect")
+	}
+	if len(status.Interfaces) != 1 || status.Interfaces[0] != "eth0" {
+		t.Fatal("Interfaces are incorrect")
+	}
+	if len(status.Events) < 2 || status.Events[0].Type != "packet_received" || status.Events[1].Type != "peer_added" {
+		t.Fatal("Expected most recent events to be the peer addition and its packet")
+	}
+}
+
+// Ensure that the status is rendered as HTML by default.
+func Test_Handler_HTML(t *testing.T) {
+
+	c := util.NewFakeClock(time.Unix(0, 0))
+	s, stop := newTestService(t, nil, c)
+	defer stop()
+
+	w := httptest.NewRecorder()
+	NewHandler(Config{Service: s, Clock: c}).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatal("Incorrect content type")
+	}
+	if !strings.Contains(w.Body.String(), "<td>b</td>") {
+		t.Fatal("Peer is missing")
+	}
+}
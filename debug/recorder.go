@@ -0,0 +1,52 @@
+package debug
+
+import (
+	"sync"
+
+	"github.com/nathan-osman/go-sdiscovery"
+)
+
+// DefaultRecorderSize is the number of events kept by a Recorder if no size
+// is specified.
+const DefaultRecorderSize = 100
+
+// Recorder is a Hook that keeps the most recent events so that they can be
+// displayed by the Handler.
+type Recorder struct {
+	mutex  sync.Mutex
+	events []sdiscovery.Event
+	next   int
+	full   bool
+}
+
+// Create a new recorder that keeps the specified number of events. If size is
+// not positive, DefaultRecorderSize is used.
+func NewRecorder(size int) *Recorder {
+	if size <= 0 {
+		size = DefaultRecorderSize
+	}
+	return &Recorder{
+		events: make([]sdiscovery.Event, size),
+	}
+}
+
+// Record the event, replacing the oldest if the recorder is full.
+func (r *Recorder) HandleEvent(e sdiscovery.Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events[r.next] = e
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Obtain the recorded events, oldest first.
+func (r *Recorder) Events() []sdiscovery.Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.full {
+		return append([]sdiscovery.Event{}, r.events[:r.next]...)
+	}
+	return append(append([]sdiscovery.Event{}, r.events[r.next:]...), r.events[:r.next]...)
+}
@@ -0,0 +1,19 @@
+package debug
+
+import (
+	"testing"
+
+	"github.com/nathan-osman/go-sdiscovery"
+)
+
+// Ensure that only the most recent events are kept, oldest first.
+func Test_Recorder(t *testing.T) {
+	r := NewRecorder(2)
+	for _, id := range []string{"a", "b", "c"} {
+		r.HandleEvent(sdiscovery.Event{PeerID: id})
+	}
+	events := r.Events()
+	if len(events) != 2 || events[0].PeerID != "b" || events[1].PeerID != "c" {
+		t.Fatal("Incorrect events recorded")
+	}
+}
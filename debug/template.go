@@ -0,0 +1,52 @@
+package debug
+
+import (
+	"html/template"
+)
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>sdiscovery</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>sdiscovery</h1>
+<p>Generated at {{.Time.Format "2006-01-02 15:04:05"}} (<a href="?format=json">JSON</a>)</p>
+
+<h2>Peers ({{len .Peers}})</h2>
+<table>
+<tr><th>ID</th><th>Attributes</th><th>Addresses</th><th>Age (s)</th></tr>
+{{range .Peers}}<tr>
+<td>{{.ID}}{{if .Stale}} (stale){{end}}</td>
+<td>{{range $k, $v := .Attributes}}{{$k}}={{$v}}<br>{{end}}</td>
+<td>{{range .Addrs}}{{.IP}} on {{.Interface}} ({{.Pings}} pings over {{printf "%.1f" .Span}}s)<br>{{end}}</td>
+<td>{{printf "%.1f" .Age}}</td>
+</tr>{{end}}
+</table>
+
+<h2>Interfaces</h2>
+<ul>{{range .Interfaces}}<li>{{.}}</li>{{end}}</ul>
+
+<h2>Connections</h2>
+<table>
+<tr><th>Interface</th><th>Type</th><th>Address</th></tr>
+{{range .Connections}}<tr><td>{{.Interface}}</td><td>{{.Type}}</td><td>{{.Addr}}</td></tr>{{end}}
+</table>
+
+<h2>Recent events</h2>
+<table>
+<tr><th>Time</th><th>Type</th><th>Peer</th><th>Interface</th><th>Address</th><th>Error</th></tr>
+{{range .Events}}<tr>
+<td>{{.Time.Format "15:04:05.000"}}</td><td>{{.Type}}</td><td>{{.PeerID}}</td>
+<td>{{.Interface}}</td><td>{{.Addr}}</td><td>{{.Error}}</td>
+</tr>{{end}}
+</table>
+</body>
+</html>
+`))
@@ -8,6 +8,7 @@
 //         Port:         1234,
 //         ID:           "machine01",
 //         UserData:     []byte("data"),
+//         Attributes:   map[string]string{"role": "worker"},
 //     })
 //
 // At this point, the service will begin sending broadcast and multicast
@@ -30,6 +31,15 @@
 //     data, _ := s.PeerUserData(id)
 //     fmt.Printf("UserData: %s\n", data)
 //
+// Attributes provide a structured alternative to user data, similar to TXT
+// records in DNS-SD. They can be retrieved in the same way:
+//
+//     attrs, _ := s.PeerAttributes(id)
+//     fmt.Printf("Role: %s\n", attrs["role"])
+//
+// Setting AttributeFilter in the ServiceConfig causes the service to ignore
+// any peers that do not have all of the specified attributes.
+//
 // If you need to connect to the peer, it is possible to obtain a slice of IP
 // addresses for the peer. As packets are received from the peer, the IP
 // address and timestamp are recored. This allows the service to determine
@@ -43,6 +53,150 @@
 // Note that you may want to filter the addresses since the slice may contain
 // both IPv4 and IPv6 addresses.
 //
+// A snapshot of every peer can be obtained with Peers(). Query() returns only
+// the peers matching a set of criteria, with non-matching addresses removed:
+//
+//     workers := s.Query(sdiscovery.Query{
+//         Attributes: map[string]string{"role": "worker"},
+//         Family:     sdiscovery.IPv4,
+//     })
+//
+// Applications that cannot proceed until a particular peer is present can
+// block until one appears. If a matching peer already exists, it is returned
+// immediately:
+//
+//     info, err := s.WaitForPeer(ctx, func(i sdiscovery.PeerInfo) bool {
+//         return i.Attributes["role"] == "coordinator"
+//     })
+//
+// By default, packets are sent using IPv4 broadcast and IPv6 multicast. Since
+// some networks filter broadcast packets, IPv4 multicast can be enabled for
+// all interfaces or specific ones. ModeDisabled turns an interface off:
+//
+//     sdiscovery.ServiceConfig{
+//         Modes: comm.ModeMulticastIPv4 | comm.ModeMulticastIPv6,
+//         InterfaceModes: map[string]comm.Mode{
+//             "eth0":    comm.ModeBroadcastIPv4,
+//             "docker0": comm.ModeDisabled,
+//         },
+//         Multicast: comm.MulticastConfig{
+//             GroupIPv4: net.IPv4(239, 255, 1, 1),
+//             TTL:       1,
+//         },
+//     }
+//
+// IPv6 multicast uses the all nodes group (ff02::1) by default, which every
+// IPv6 host must process. A dedicated group, scope and hop limit can be used
+// instead, which also allows packets to cross routers:
+//
+//     comm.MulticastConfig{
+//         GroupIPv6: net.ParseIP("ff02::5344"),
+//         Scope:     comm.ScopeSiteLocal,
+//         HopLimit:  4,
+//     }
+//
+// All interfaces are used by default. The interfaces can be restricted by name,
+// flags or address range, with the filter re-evaluated each time interfaces
+// are polled:
+//
+//     sdiscovery.ServiceConfig{
+//         InterfaceFilter: comm.InterfaceFilter{
+//             Exclude:      []string{"docker*", "veth*"},
+//             RequireFlags: net.FlagUp,
+//             RejectFlags:  net.FlagLoopback | net.FlagPointToPoint,
+//         },
+//     }
+//
+// Several instances on the same machine are not reliably discovered using the
+// network alone, since loopback interfaces usually lack broadcast support. The
+// LocalHost option enables multicast loopback and also exchanges packets with
+// other processes run by the same user through Unix sockets in a private
+// directory:
+//
+//     sdiscovery.ServiceConfig{
+//         LocalHost: true,
+//     }
+//
+// Warnings and errors are written to the standard log package by default.
+// They can instead be sent to any slog.Handler, which also receives debug
+// messages with structured fields such as the interface and peer ID:
+//
+//     sdiscovery.ServiceConfig{
+//         Logger: util.NewSlogLogger(slog.NewJSONHandler(os.Stderr, nil)),
+//     }
+//
+// Problems that degrade discovery, such as malformed packets or sockets that
+// cannot be created, are also delivered on the channel returned by Errors():
+//
+//     for err := range s.Errors() {
+//         if errors.Is(err, comm.ErrSocket) {
+//             // alert
+//         }
+//     }
+//
+// Packet counts, peer counts and the time since each peer was last seen can be
+// recorded by setting Metrics. The metrics package includes an Exporter that
+// serves them in the Prometheus text format:
+//
+//     e := metrics.NewExporter(nil)
+//     http.Handle("/metrics", e)
+//     s := sdiscovery.New(sdiscovery.ServiceConfig{
+//         Metrics: e,
+//     })
+//
+// The lifecycle of peers and interfaces can be followed by setting Hook. The
+// tracing package provides a Tracer that records each peer and interface as
+// a span containing the updates and errors that occurred and the number of
+// packets received:
+//
+//     e := &tracing.InMemoryExporter{}
+//     s := sdiscovery.New(sdiscovery.ServiceConfig{
+//         Hook: tracing.NewTracer(e),
+//     })
+//
+// The debug package provides an http.Handler that displays the peers,
+// interfaces, connections and recent events of a service as HTML or JSON:
+//
+//     r := debug.NewRecorder(0)
+//     s := sdiscovery.New(sdiscovery.ServiceConfig{
+//         Hook: r,
+//     })
+//     http.Handle("/debug/sdiscovery", debug.NewHandler(debug.Config{
+//         Service:  s,
+//         Recorder: r,
+//     }))
+//
+// After a restart, peers are normally unknown until their next ping arrives.
+// Setting Store restores the peers known when the service last ran, unless
+// they were last seen more than StoreMaxAge (one hour by default) ago. They
+// are marked as stale in PeerInfo until a packet confirms them and are
+// removed after PeerTimeout otherwise:
+//
+//     s := sdiscovery.New(sdiscovery.ServiceConfig{
+//         Store:       store.NewFileStore("/var/lib/myapp/peers.json"),
+//         StoreMaxAge: 24 * time.Hour,
+//     })
+//
+// Datagrams received by the service can be recorded to a pcapng file by
+// setting Sniff. A recorded file can later be fed back into a service in
+// simulated time, which turns a capture from the field into a regression test:
+//
+//     f, _ := os.Create("discovery.pcapng")
+//     w, _ := capture.NewWriter(f)
+//     s := sdiscovery.New(sdiscovery.ServiceConfig{
+//         Sniff: w.Sniff,
+//     })
+//
+//     records, _ := capture.ReadFile("discovery.pcapng")
+//     c := util.NewFakeClock(records[0].Time)
+//     s := sdiscovery.New(sdiscovery.ServiceConfig{
+//         Transport: capture.NewReplay(records, c),
+//         Clock:     c,
+//     })
+//
+// Mechanisms other than UDP can be used instead by setting Transport in the
+// ServiceConfig to an implementation of the comm.Transport interface.
+//
 // The service can be shutdown by invoking the Stop() method:
 //
 //     s.Stop()
@@ -0,0 +1,75 @@
+package sdiscovery
+
+import (
+	"net"
+	"time"
+)
+
+// EventType indicates what happened in the lifecycle of a peer or interface.
+type EventType int
+
+const (
+	EventPacketReceived   EventType = iota // a packet was received from a peer
+	EventPeerAdded                         // a new peer was found
+	EventPeerUpdated                       // the data or addresses of a peer changed
+	EventPeerRemoved                       // a peer timed out
+	EventInterfaceAdded                    // the transport began using an interface
+	EventInterfaceRemoved                  // the transport stopped using an interface
+	EventError                             // a socket or other error occurred
+)
+
+// Return the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventPacketReceived:
+		return "packet_received"
+	case EventPeerAdded:
+		return "peer_added"
+	case EventPeerUpdated:
+		return "peer_updated"
+	case EventPeerRemoved:
+		return "peer_removed"
+	case EventInterfaceAdded:
+		return "interface_added"
+	case EventInterfaceRemoved:
+		return "interface_removed"
+	case EventError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Event describes a single occurrence in the lifecycle of a peer or
+// interface. Fields that do not apply to the type of event are left empty.
+type Event struct {
+	Type      EventType // what happened
+	Time      time.Time // when it happened
+	PeerID    string    // ID of the peer (if any)
+	Interface string    // name of the interface (if any)
+	Addr      net.IP    // address of the peer (if any)
+	Err       error     // error that occurred (for EventError)
+}
+
+// Hook receives events from the service. HandleEvent may be invoked from
+// several goroutines at once and must not block.
+type Hook interface {
+	HandleEvent(e Event)
+}
+
+// HookFunc allows an ordinary function to be used as a Hook.
+type HookFunc func(e Event)
+
+// Invoke the function with the event.
+func (f HookFunc) HandleEvent(e Event) {
+	f(e)
+}
+
+// Send an event to the hook, if one was provided.
+func (s *Service) emit(e Event) {
+	if s.config.Hook != nil {
+		if e.Time.IsZero() {
+			e.Time = s.config.Clock.Now()
+		}
+		s.config.Hook.HandleEvent(e)
+	}
+}
@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Labels identifying a counter. Unused labels are empty.
+type labels struct {
+	ifi    string
+	pType  string
+	reason string
+}
+
+// Exporter records measurements in memory and writes them in the Prometheus
+// text exposition format. It can be registered as an http.Handler so that the
+// measurements can be scraped without any other dependencies.
+type Exporter struct {
+	mutex    sync.Mutex
+	clock    util.Clock
+	sent     map[labels]uint64
+	received map[labels]uint64
+	dropped  map[labels]uint64
+	decode   map[labels]uint64
+	peers    int
+	added    uint64
+	removed  uint64
+	flaps    uint64
+	lastSeen map[string]time.Time
+}
+
+// Create a new exporter. The clock is used for calculating how long ago each
+// peer was seen. If clock is nil, util.SystemClock is used.
+func NewExporter(clock util.Clock) *Exporter {
+	if clock == nil {
+		clock = util.SystemClock
+	}
+	return &Exporter{
+		clock:    clock,
+		sent:     make(map[labels]uint64),
+		received: make(map[labels]uint64),
+		dropped:  make(map[labels]uint64),
+		decode:   make(map[labels]uint64),
+		lastSeen: make(map[string]time.Time),
+	}
+}
+
+// Count a packet sent on the interface.
+func (e *Exporter) PacketSent(ifi, pType string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.sent[labels{ifi: ifi, pType: pType}]++
+}
+
+// Count a packet received on the interface.
+func (e *Exporter) PacketReceived(ifi, pType string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.received[labels{ifi: ifi, pType: pType}]++
+}
+
+// Count a packet dropped on the interface for the specified reason.
+func (e *Exporter) PacketDropped(ifi, pType, reason string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.dropped[labels{ifi: ifi, pType: pType, reason: reason}]++
+}
+
+// Count a datagram on the interface that could not be decoded.
+func (e *Exporter) DecodeError(ifi, pType string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.decode[labels{ifi: ifi, pType: pType}]++
+}
+
+// Record the number of peers currently known.
+func (e *Exporter) PeerCount(n int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.peers = n
+}
+
+// Count a peer that was added.
+func (e *Exporter) PeerAdded(id string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.added++
+}
+
+// Record that a peer was removed, which also removes its last-seen age.
+func (e *Exporter) PeerRemoved(id string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.removed++
+	delete(e.lastSeen, id)
+}
+
+// Count a peer that was added again shortly after being removed.
+func (e *Exporter) PeerFlapped(id string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.flaps++
+}
+
+// Record the time at which a packet was last received from the peer.
+func (e *Exporter) PeerSeen(id string, t time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.lastSeen[id] = t
+}
+
+// Escape a label value as required by the text format.
+func escape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// Format the labels that are in use.
+func (l labels) String() string {
+	parts := []string{
+		fmt.Sprintf(`interface="%s"`, escape(l.ifi)),
+		fmt.Sprintf(`type="%s"`, escape(l.pType)),
+	}
+	if l.reason != "" {
+		parts = append(parts, fmt.Sprintf(`reason="%s"`, escape(l.reason)))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// Write a counter with a sample for each set of labels, sorted so that the
+// output is stable.
+func writeCounter(w io.Writer, name, help string, values map[labels]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]labels, 0, len(values))
+	for l := range values {
+		keys = append(keys, l)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	for _, l := range keys {
+		fmt.Fprintf(w, "%s%s %d\n", name, l, values[l])
+	}
+}
+
+// Write a metric with a single unlabelled sample.
+func writeSingle(w io.Writer, name, help, kind string, value interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+}
+
+// Write all measurements to w in the Prometheus text exposition format.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+
+	// Obtain exclusive access to the measurements.
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	b := bufio.NewWriter(w)
+	c := &countingWriter{w: b}
+
+	writeCounter(c, "sdiscovery_packets_sent_total", "Packets sent.", e.sent)
+	writeCounter(c, "sdiscovery_packets_received_total", "Packets received from peers.", e.received)
+	writeCounter(c, "sdiscovery_packets_dropped_total", "Packets received and ignored.", e.dropped)
+	writeCounter(c, "sdiscovery_decode_errors_total", "Packets that could not be decoded.", e.decode)
+	writeSingle(c, "sdiscovery_peers", "Peers currently known.", "gauge", e.peers)
+	writeSingle(c, "sdiscovery_peers_added_total", "Peers found.", "counter", e.added)
+	writeSingle(c, "sdiscovery_peers_removed_total", "Peers that timed out.", "counter", e.removed)
+	writeSingle(c, "sdiscovery_peer_flaps_total", "Peers that returned shortly after timing out.", "counter", e.flaps)
+
+	// Write the age of each peer, sorted by ID.
+	const name = "sdiscovery_peer_last_seen_age_seconds"
+	fmt.Fprintf(c, "# HELP %s Seconds since a packet was received from the peer.\n# TYPE %s gauge\n", name, name)
+	ids := make([]string, 0, len(e.lastSeen))
+	for id := range e.lastSeen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	now := e.clock.Now()
+	for _, id := range ids {
+		fmt.Fprintf(c, "%s{peer=\"%s\"} %g\n", name, escape(id), now.Sub(e.lastSeen[id]).Seconds())
+	}
+
+	if c.err == nil {
+		c.err = b.Flush()
+	}
+	return c.n, c.err
+}
+
+// Write the measurements in response to an HTTP request.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.WriteTo(w)
+}
+
+// countingWriter keeps track of the number of bytes written and the first
+// error encountered.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Ensure that measurements are written in the text format.
+func Test_Exporter(t *testing.T) {
+
+	c := util.NewFakeClock(time.Unix(100, 0))
+	e := NewExporter(c)
+	e.PacketSent("eth0", "broadcast")
+	e.PacketSent("eth0", "broadcast")
+	e.PacketReceived("eth0", "multicast")
+	e.PacketDropped("eth0", "broadcast", "self")
+	e.DecodeError("eth1", "multicast")
+	e.PeerAdded("a")
+	e.PeerAdded("b")
+	e.PeerSeen("a", time.Unix(100, 0))
+	e.PeerSeen("b", time.Unix(100, 0))
+	e.PeerRemoved("b")
+	e.PeerFlapped("a")
+	e.PeerCount(1)
+	c.Advance(2 * time.Second)
+
+	var b bytes.Buffer
+	if _, err := e.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, line := range []string{
+		`sdiscovery_packets_sent_total{interface="eth0",type="broadcast"} 2`,
+		`sdiscovery_packets_received_total{interface="eth0",type="multicast"} 1`,
+		`sdiscovery_packets_dropped_total{interface="eth0",type="broadcast",reason="self"} 1`,
+		`sdiscovery_decode_errors_total{interface="eth1",type="multicast"} 1`,
+		`sdiscovery_peers 1`,
+		`sdiscovery_peers_added_total 2`,
+		`sdiscovery_peers_removed_total 1`,
+		`sdiscovery_peer_flaps_total 1`,
+		`sdiscovery_peer_last_seen_age_seconds{peer="a"} 2`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Fatalf("Missing %q", line)
+		}
+	}
+	if strings.Contains(out, `peer="b"`) {
+		t.Fatal("Removed peer is still present")
+	}
+}
+
+// Ensure that the exporter can be scraped over HTTP.
+func Test_Exporter_ServeHTTP(t *testing.T) {
+	e := NewExporter(nil)
+	e.PeerCount(3)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatal("Incorrect content type")
+	}
+	if !strings.Contains(w.Body.String(), "sdiscovery_peers 3\n") {
+		t.Fatal("Missing peer count")
+	}
+}
+
+// Ensure that label values are escaped.
+func Test_escape(t *testing.T) {
+	if v := escape("a\"b\\c\nd"); v != `a\"b\\c\nd` {
+		t.Fatal(v)
+	}
+}
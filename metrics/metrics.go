@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"time"
+)
+
+// Metrics receives measurements of discovery health from the service and the
+// communicator. Interface names and packet types are passed as labels so that
+// problems can be traced to a specific connection. Implementations must be
+// safe for concurrent use and should not block.
+type Metrics interface {
+	PacketSent(ifi, pType string)            // a packet was sent
+	PacketReceived(ifi, pType string)        // a valid packet was received
+	PacketDropped(ifi, pType, reason string) // a packet was received and ignored
+	DecodeError(ifi, pType string)           // a packet could not be decoded
+	PeerCount(n int)                         // the number of peers changed
+	PeerAdded(id string)                     // a new peer was found
+	PeerRemoved(id string)                   // a peer timed out
+	PeerFlapped(id string)                   // a peer returned shortly after timing out
+	PeerSeen(id string, t time.Time)         // a packet was received from a peer
+}
+
+// Discard ignores all measurements.
+var Discard Metrics = discard{}
+
+type discard struct{}
+
+func (discard) PacketSent(ifi, pType string)            {}
+func (discard) PacketReceived(ifi, pType string)        {}
+func (discard) PacketDropped(ifi, pType, reason string) {}
+func (discard) DecodeError(ifi, pType string)           {}
+func (discard) PeerCount(n int)                         {}
+func (discard) PeerAdded(id string)                     {}
+func (discard) PeerRemoved(id string)                   {}
+func (discard) PeerFlapped(id string)                   {}
+func (discard) PeerSeen(id string, t time.Time)         {}
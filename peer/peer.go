@@ -1,21 +1,45 @@
 package peer
 
 import (
+	"bytes"
 	"net"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/nathan-osman/go-sdiscovery/comm"
+	"github.com/nathan-osman/go-sdiscovery/util"
 )
 
 type peerSlice []*peerAddr
 
+// AddrInfo describes a single address from which packets have been received
+// and the interface on which they arrived. Span is the time covered by the
+// most recent pings (Pings of them) and is used for ranking addresses; the
+// lower the better.
+type AddrInfo struct {
+	IP        net.IP
+	Interface string
+	LastSeen  time.Time
+	Pings     int
+	Span      time.Duration
+}
+
 // Peer maintains information about a peer discovered on the network. Because
 // the struct may be used from multiple goroutines, all access to members must
-// be done through accessors that lock a mutex.
+// be done through accessors that lock a mutex. Accessors return copies so that
+// callers cannot modify the internal state of the peer.
+//
+// A peer restored from a cache is stale until the first ping is recorded.
+// Until then, its addresses report the time that the peer was last seen
+// before it was cached.
 type Peer struct {
-	UserData []byte
-	addrs    peerSlice
+	mutex      sync.Mutex
+	userData   []byte
+	attributes map[string]string
+	addrs      peerSlice
+	stale      bool
+	lastSeen   time.Time
 }
 
 func (a peerSlice) Len() int           { return len(a) }
@@ -25,39 +49,183 @@ func (a peerSlice) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 // Record a ping from the specified address.
 func (p *Peer) Ping(pkt *comm.Packet, curTime time.Time) {
 
-	// Store userData.
-	p.UserData = pkt.UserData
+	// Obtain exclusive access to the peer.
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// The peer has been confirmed by live traffic.
+	p.stale = false
 
-	// Attempt to find a matching address.
+	// Store copies of userData and attributes.
+	p.userData = util.CopyBytes(pkt.UserData)
+	p.attributes = util.CopyStringMap(pkt.Attributes)
+
+	// Attempt to find a matching address. The interface must also match
+	// since link-local addresses may be reused on different interfaces.
 	for _, addr := range p.addrs {
-		if pkt.IP.Equal(addr.ip) {
+		if pkt.IP.Equal(addr.ip) && pkt.Interface == addr.ifiName {
 			addr.ping(curTime)
 			return
 		}
 	}
 
 	// No matching address was found, add a new one.
-	p.addrs = append(p.addrs, newPeerAddr(pkt.IP, curTime))
+	p.addrs = append(p.addrs, newPeerAddr(util.CopyIP(pkt.IP), pkt.Interface, curTime))
 }
 
-// Obtain a sorted list of all addresses for the peer.
-func (p *Peer) Addrs() []net.IP {
+// Restore a peer from cached information, marking it as stale. The peer was
+// last seen at lastSeen, but each address is treated as if a ping was
+// received at curTime so that the peer expires normally unless it is
+// confirmed by a ping.
+func (p *Peer) Restore(userData []byte, attributes map[string]string, addrs []AddrInfo, lastSeen, curTime time.Time) {
+
+	// Obtain exclusive access to the peer.
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.stale = true
+	p.lastSeen = lastSeen
+	p.userData = util.CopyBytes(userData)
+	p.attributes = util.CopyStringMap(attributes)
+	p.addrs = nil
+	for _, addr := range addrs {
+		p.addrs = append(p.addrs, newPeerAddr(util.CopyIP(addr.IP), addr.Interface, curTime))
+	}
+}
+
+// Determine if the peer was restored from a cache and has not been confirmed
+// by a ping.
+func (p *Peer) Stale() bool {
+
+	// Obtain exclusive access to the peer.
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.stale
+}
+
+// Determine if recording the packet would change the user data, attributes or
+// addresses of the peer. Confirming a stale peer is always a change.
+func (p *Peer) Changed(pkt *comm.Packet) bool {
+
+	// Obtain exclusive access to the peer.
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.stale {
+		return true
+	}
+
+	// Compare the user data and attributes.
+	if !bytes.Equal(p.userData, pkt.UserData) || len(p.attributes) != len(pkt.Attributes) {
+		return true
+	}
+	for k, v := range pkt.Attributes {
+		if pv, ok := p.attributes[k]; !ok || pv != v {
+			return true
+		}
+	}
+
+	// Check whether the address is new.
+	for _, addr := range p.addrs {
+		if pkt.IP.Equal(addr.ip) && pkt.Interface == addr.ifiName {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Obtain a copy of the custom user data provided by the peer.
+func (p *Peer) UserData() []byte {
+
+	// Obtain exclusive access to the peer.
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return util.CopyBytes(p.userData)
+}
+
+// Obtain a copy of the key/value attributes provided by the peer.
+func (p *Peer) Attributes() map[string]string {
 
-	// First sort the addresses
-	sort.Sort(p.addrs)
+	// Obtain exclusive access to the peer.
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
 
-	// Build a slice of IP addresses
-	ips := make([]net.IP, len(p.addrs))
-	for i, addr := range p.addrs {
-		ips[i] = addr.ip
+	return util.CopyStringMap(p.attributes)
+}
+
+// Obtain a sorted list of all addresses for the peer. An address seen on more
+// than one interface is only included once, in the position of its best
+// entry.
+func (p *Peer) Addrs() []net.IP {
+
+	// Build a slice of IP addresses from the sorted address information,
+	// skipping those already added.
+	infos := p.AddrInfo()
+	ips := make([]net.IP, 0, len(infos))
+	for _, info := range infos {
+		if !containsIP(ips, info.IP) {
+			ips = append(ips, info.IP)
+		}
 	}
 
 	return ips
 }
 
+// Determine if the slice contains the specified IP address.
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, i := range ips {
+		if i.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Obtain a sorted list of all addresses for the peer along with the interface
+// on which each address was seen.
+func (p *Peer) AddrInfo() []AddrInfo {
+
+	// Obtain exclusive access to the peer.
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// First sort a copy of the addresses so that the order of the internal
+	// slice is left untouched
+	addrs := append(peerSlice{}, p.addrs...)
+	sort.Sort(addrs)
+
+	// Build a slice of address information with copies of each IP
+	infos := make([]AddrInfo, len(addrs))
+	for i, addr := range addrs {
+		infos[i] = AddrInfo{
+			IP:        util.CopyIP(addr.ip),
+			Interface: addr.ifiName,
+			LastSeen:  addr.lastSeen(),
+			Pings:     addr.pings(),
+			Span:      addr.duration(),
+		}
+	}
+
+	// Stale peers have not been seen since they were cached.
+	if p.stale {
+		for i := range infos {
+			infos[i].LastSeen = p.lastSeen
+		}
+	}
+
+	return infos
+}
+
 // Remove all expired addresses and sort those that remain.
 func (p *Peer) IsExpired(timeout time.Duration, curTime time.Time) bool {
 
+	// Obtain exclusive access to the peer.
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
 	// Create an empty slice pointing to the old array and filter the
 	// addresses based on whether they have expired or not.
 	addrs := p.addrs[:0]
@@ -1,6 +1,7 @@
 package peer
 
 import (
+	"net"
 	"testing"
 	"time"
 
@@ -24,14 +25,60 @@ func Test_Peer_Ping(t *testing.T) {
 	}
 }
 
+// Ensure that pings update the attributes of the peer.
+func Test_Peer_Ping_Attributes(t *testing.T) {
+
+	// Create an empty peer and ping it with a packet containing attributes.
+	p := &Peer{}
+	p.Ping(&comm.Packet{Attributes: map[string]string{"a": "1"}}, testTime1)
+
+	// Ensure the attributes were stored.
+	if p.Attributes()["a"] != "1" {
+		t.Fatal("Attributes were not stored")
+	}
+}
+
+// Ensure that changes to the data or addresses of a peer are detected.
+func Test_Peer_Changed(t *testing.T) {
+
+	// Ping the peer with a packet.
+	pkt := &comm.Packet{
+		IP:         net.IPv4(192, 168, 1, 1),
+		Interface:  "eth0",
+		UserData:   []byte("a"),
+		Attributes: map[string]string{"a": "1"},
+	}
+	p := &Peer{}
+	if !p.Changed(pkt) {
+		t.Fatal("Expected an empty peer to be changed")
+	}
+	p.Ping(pkt, testTime1)
+
+	// The same packet should not result in a change.
+	if p.Changed(pkt) {
+		t.Fatal("Expected identical packet to leave peer unchanged")
+	}
+
+	// Changing any of the fields should result in a change.
+	for _, c := range []*comm.Packet{
+		{IP: pkt.IP, Interface: "eth1", UserData: pkt.UserData, Attributes: pkt.Attributes},
+		{IP: pkt.IP, Interface: "eth0", UserData: []byte("b"), Attributes: pkt.Attributes},
+		{IP: pkt.IP, Interface: "eth0", UserData: pkt.UserData, Attributes: map[string]string{"a": "2"}},
+	} {
+		if !p.Changed(c) {
+			t.Fatal("Expected change to be detected")
+		}
+	}
+}
+
 // Ensure that Addrs() returns a properly sorted slice of addresses.
 func Test_Peer_Addrs(t *testing.T) {
 
 	// Create a peer with two addresses one second apart.
 	p := &Peer{
 		addrs: peerSlice{
-			newPeerAddr(testIP1, testTime1),
-			newPeerAddr(testIP2, testTime2),
+			newPeerAddr(testIP1, "", testTime1),
+			newPeerAddr(testIP2, "", testTime2),
 		},
 	}
 
@@ -49,12 +96,63 @@ func Test_Peer_Addrs(t *testing.T) {
 	}
 }
 
+// Ensure that an address seen on more than one interface is only returned
+// once by Addrs().
+func Test_Peer_Addrs_Duplicate(t *testing.T) {
+
+	// Create a peer with the same address on two interfaces.
+	p := &Peer{
+		addrs: peerSlice{
+			newPeerAddr(testIP1, "eth0", testTime1),
+			newPeerAddr(testIP2, "eth0", testTime2),
+			newPeerAddr(testIP1, "eth1", testTime2),
+		},
+	}
+
+	// Both entries should be described but the address listed only once.
+	if len(p.AddrInfo()) != 3 {
+		t.Fatal("Expected exactly three entries")
+	}
+	addrs := p.Addrs()
+	if len(addrs) != 2 || !addrs[0].Equal(testIP1) || !addrs[1].Equal(testIP2) {
+		t.Fatal("Expected each address exactly once")
+	}
+}
+
+// Ensure that values returned by accessors do not alias internal state.
+func Test_Peer_Copies(t *testing.T) {
+
+	// Create a peer with user data, attributes and an address.
+	p := &Peer{}
+	p.Ping(&comm.Packet{
+		IP:         testIP1,
+		UserData:   []byte("data"),
+		Attributes: map[string]string{"a": "1"},
+	}, testTime1)
+
+	// Modify each of the values returned.
+	p.UserData()[0] = 'x'
+	p.Attributes()["a"] = "2"
+	p.Addrs()[0][15] = 0
+
+	// Ensure the peer is unchanged.
+	if string(p.UserData()) != "data" {
+		t.Fatal("User data was modified")
+	}
+	if p.Attributes()["a"] != "1" {
+		t.Fatal("Attributes were modified")
+	}
+	if !p.Addrs()[0].Equal(testIP1) {
+		t.Fatal("Address was modified")
+	}
+}
+
 // Ensure that the peer expires when the addresses expire.
 func Test_Peer_IsExpired(t *testing.T) {
 
 	// Create a peer with an expired address.
 	p := &Peer{
-		addrs: peerSlice{newPeerAddr(testIP1, testTime1)},
+		addrs: peerSlice{newPeerAddr(testIP1, "", testTime1)},
 	}
 
 	// The peer should have now expired.
@@ -62,3 +160,34 @@ func Test_Peer_IsExpired(t *testing.T) {
 		t.Fatal("Peer should be expired")
 	}
 }
+
+// Ensure that a restored peer is stale until it is pinged.
+func Test_Peer_Restore(t *testing.T) {
+
+	// Restore a peer with a single address.
+	p := &Peer{}
+	p.Restore([]byte("data"), map[string]string{"a": "1"}, []AddrInfo{
+		{IP: testIP1, Interface: "eth0"},
+	}, testTime1, testTime2)
+	if !p.Stale() || string(p.UserData()) != "data" || p.Attributes()["a"] != "1" {
+		t.Fatal("Peer was not restored")
+	}
+	if infos := p.AddrInfo(); len(infos) != 1 || infos[0].Interface != "eth0" || !infos[0].LastSeen.Equal(testTime1) {
+		t.Fatal("Addresses were not restored")
+	}
+
+	// The addresses should expire relative to the time of restoration.
+	if p.IsExpired(time.Second, testTime2) {
+		t.Fatal("Peer expired too early")
+	}
+
+	// A ping should always be a change and confirm the peer.
+	pkt := &comm.Packet{IP: testIP1, Interface: "eth0", UserData: []byte("data"), Attributes: map[string]string{"a": "1"}}
+	if !p.Changed(pkt) {
+		t.Fatal("Expected confirmation to be a change")
+	}
+	p.Ping(pkt, testTime2)
+	if p.Stale() || p.Changed(pkt) {
+		t.Fatal("Peer was not confirmed")
+	}
+}
@@ -11,15 +11,17 @@ import (
 // case, the lower the duration, the better.
 type peerAddr struct {
 	ip       net.IP
+	ifiName  string
 	lastPing *ring.Ring
 }
 
 // Create a new peerAddr.
-func newPeerAddr(ip net.IP, curTime time.Time) *peerAddr {
+func newPeerAddr(ip net.IP, ifiName string, curTime time.Time) *peerAddr {
 
 	// Create the new peer address.
 	p := &peerAddr{
 		ip:       ip,
+		ifiName:  ifiName,
 		lastPing: ring.New(6),
 	}
 
@@ -47,6 +49,22 @@ func (p *peerAddr) duration() time.Duration {
 	return p.lastPing.Value.(time.Time).Sub(oldestPing)
 }
 
+// Obtain the time of the most recent ping.
+func (p *peerAddr) lastSeen() time.Time {
+	return p.lastPing.Value.(time.Time)
+}
+
+// Count the number of pings currently recorded.
+func (p *peerAddr) pings() int {
+	n := 0
+	p.lastPing.Do(func(v interface{}) {
+		if v != nil {
+			n++
+		}
+	})
+	return n
+}
+
 // Determine if the address has exceeded the specified timeout.
 func (p *peerAddr) isExpired(timeout time.Duration, curTime time.Time) bool {
 	return curTime.Sub(p.lastPing.Value.(time.Time)) >= timeout
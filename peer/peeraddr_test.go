@@ -30,7 +30,7 @@ func validElementsInRing(r *ring.Ring) int {
 func Test_peerAddr_ping(t *testing.T) {
 
 	// Create a new peerAddr and confirm that it contains one item.
-	p := newPeerAddr(nil, testTime1)
+	p := newPeerAddr(nil, "", testTime1)
 	if validElementsInRing(p.lastPing) != 1 {
 		t.Fatal("Expected one element in ring")
 	}
@@ -47,7 +47,7 @@ func Test_peerAddr_ping(t *testing.T) {
 func Test_peerAddr_duration(t *testing.T) {
 
 	// Create a peerAddr.
-	p := newPeerAddr(nil, testTime1)
+	p := newPeerAddr(nil, "", testTime1)
 
 	// Ping the address five more times.
 	for i := 0; i < 5; i++ {
@@ -60,11 +60,26 @@ func Test_peerAddr_duration(t *testing.T) {
 	}
 }
 
+// Ensure that the statistics reflect the pings received.
+func Test_peerAddr_stats(t *testing.T) {
+
+	// Create a peerAddr and ping it once more.
+	p := newPeerAddr(nil, "", testTime1)
+	p.ping(testTime2)
+
+	if !p.lastSeen().Equal(testTime2) {
+		t.Fatal("Last ping time does not match")
+	}
+	if p.pings() != 2 {
+		t.Fatal("Expected two pings")
+	}
+}
+
 // Ensure that the address expires when the duration is exceeded.
 func Test_peerAddr_isExpired(t *testing.T) {
 
 	// Create a new peerAddr with the first time.
-	p := newPeerAddr(nil, testTime1)
+	p := newPeerAddr(nil, "", testTime1)
 
 	// Assuming a timeout of one second, the address should have expired.
 	if !p.isExpired(500*time.Millisecond, testTime2) {
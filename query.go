@@ -0,0 +1,142 @@
+package sdiscovery
+
+import (
+	"sort"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/peer"
+)
+
+// AddrFamily restricts a query to addresses of a specific IP version.
+type AddrFamily int
+
+const (
+	AnyFamily AddrFamily = iota // addresses of any family
+	IPv4                        // IPv4 addresses only
+	IPv6                        // IPv6 addresses only
+)
+
+// PeerInfo is a snapshot of a single peer. It is a copy of the data stored by
+// the service and may be freely modified by the caller.
+type PeerInfo struct {
+	ID         string            // unique identifier for the peer
+	UserData   []byte            // custom data provided by the peer
+	Attributes map[string]string // key/value attributes provided by the peer
+	Addrs      []peer.AddrInfo   // addresses for the peer, best first
+	LastSeen   time.Time         // time of the most recent packet from the peer
+	Stale      bool              // restored from the store and not yet confirmed
+}
+
+// Query describes the criteria used for selecting peers. Each of the criteria
+// is optional and a peer must satisfy all of those that are set. Addresses
+// that do not match Family or Interface are removed from the results and
+// peers with no remaining addresses are excluded.
+type Query struct {
+	Attributes map[string]string   // attributes that the peer must have
+	Family     AddrFamily          // family of addresses to include
+	Interface  string              // name of the interface the peer was seen on
+	Predicate  func(PeerInfo) bool // arbitrary test applied to each peer
+}
+
+// Determine if the address satisfies the family and interface criteria.
+func (q *Query) matchAddr(addr peer.AddrInfo) bool {
+	if q.Interface != "" && q.Interface != addr.Interface {
+		return false
+	}
+	switch q.Family {
+	case IPv4:
+		return addr.IP.To4() != nil
+	case IPv6:
+		return addr.IP.To4() == nil
+	}
+	return true
+}
+
+// Apply the query to a peer, returning false if it does not match. Addresses
+// that do not match are removed from the peer.
+func (q *Query) match(info *PeerInfo) bool {
+
+	// Check the attributes first since it is the cheapest test.
+	if !matchAttributes(info.Attributes, q.Attributes) {
+		return false
+	}
+
+	// Filter the addresses, ensuring that at least one remains.
+	if q.Family != AnyFamily || q.Interface != "" {
+		addrs := info.Addrs[:0]
+		for _, addr := range info.Addrs {
+			if q.matchAddr(addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+		if len(addrs) == 0 {
+			return false
+		}
+		info.Addrs = addrs
+	}
+
+	// Run the predicate last, since it receives the filtered peer.
+	return q.Predicate == nil || q.Predicate(*info)
+}
+
+// Create a snapshot of the specified peer. The map must be locked. Each of the
+// peer accessors returns a copy, so the snapshot shares no memory with it.
+func newPeerInfo(id string, p *peer.Peer) PeerInfo {
+	info := PeerInfo{
+		ID:         id,
+		UserData:   p.UserData(),
+		Attributes: p.Attributes(),
+		Addrs:      p.AddrInfo(),
+		Stale:      p.Stale(),
+	}
+	for _, addr := range info.Addrs {
+		if addr.LastSeen.After(info.LastSeen) {
+			info.LastSeen = addr.LastSeen
+		}
+	}
+	return info
+}
+
+type peerInfoSlice []PeerInfo
+
+func (a peerInfoSlice) Len() int           { return len(a) }
+func (a peerInfoSlice) Less(i, j int) bool { return a[i].ID < a[j].ID }
+func (a peerInfoSlice) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// Create a snapshot of every peer, sorted by ID.
+func (s *Service) snapshot() []PeerInfo {
+
+	// Obtain exclusive access to the map.
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	infos := make([]PeerInfo, 0, len(s.peers))
+	for id, p := range s.peers {
+		infos = append(infos, newPeerInfo(id, p))
+	}
+	sort.Sort(peerInfoSlice(infos))
+
+	return infos
+}
+
+// Obtain a snapshot of all peers currently known to the service, sorted by ID.
+func (s *Service) Peers() []PeerInfo {
+	return s.snapshot()
+}
+
+// Obtain a snapshot of all peers that satisfy the query, sorted by ID. The
+// predicate (if any) is invoked without the service lock held and may
+// therefore safely call other methods on the service.
+func (s *Service) Query(q Query) []PeerInfo {
+
+	// Filter the snapshot in place.
+	infos := s.snapshot()
+	matches := infos[:0]
+	for _, info := range infos {
+		if q.match(&info) {
+			matches = append(matches, info)
+		}
+	}
+
+	return matches
+}
@@ -0,0 +1,79 @@
+package sdiscovery
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/comm"
+	"github.com/nathan-osman/go-sdiscovery/peer"
+)
+
+// Create a service with two peers but without starting it.
+func newQueryTestService() *Service {
+
+	s := &Service{peers: make(peerMap)}
+
+	p1 := &peer.Peer{}
+	p1.Ping(&comm.Packet{
+		IP:         net.IPv4(192, 168, 1, 1),
+		Interface:  "eth0",
+		Attributes: map[string]string{"role": "worker"},
+	}, time.Now())
+	s.peers["1"] = p1
+
+	p2 := &peer.Peer{}
+	p2.Ping(&comm.Packet{
+		IP:         net.ParseIP("fe80::1"),
+		Interface:  "wlan0",
+		Attributes: map[string]string{"role": "coordinator"},
+	}, time.Now())
+	s.peers["2"] = p2
+
+	return s
+}
+
+// Ensure that Peers() returns a sorted copy of every peer.
+func Test_Service_Peers(t *testing.T) {
+
+	s := newQueryTestService()
+
+	// Ensure both peers are returned in order.
+	infos := s.Peers()
+	if len(infos) != 2 || infos[0].ID != "1" || infos[1].ID != "2" {
+		t.Fatal("Expected both peers sorted by ID")
+	}
+
+	// Modifying the snapshot should not affect the service.
+	infos[0].Attributes["role"] = "modified"
+	if attrs, _ := s.PeerAttributes("1"); attrs["role"] != "worker" {
+		t.Fatal("Snapshot aliases internal attributes")
+	}
+}
+
+// Ensure that each of the query criteria is applied.
+func Test_Service_Query(t *testing.T) {
+
+	s := newQueryTestService()
+
+	for _, c := range []struct {
+		name string
+		q    Query
+		id   string
+	}{
+		{"attributes", Query{Attributes: map[string]string{"role": "worker"}}, "1"},
+		{"family", Query{Family: IPv6}, "2"},
+		{"interface", Query{Interface: "eth0"}, "1"},
+		{"predicate", Query{Predicate: func(i PeerInfo) bool { return i.ID == "2" }}, "2"},
+	} {
+		infos := s.Query(c.q)
+		if len(infos) != 1 || infos[0].ID != c.id {
+			t.Fatalf("Query by %s returned incorrect peers", c.name)
+		}
+	}
+
+	// Criteria that nothing satisfies should return no peers.
+	if len(s.Query(Query{Family: IPv4, Interface: "wlan0"})) != 0 {
+		t.Fatal("Expected no peers")
+	}
+}
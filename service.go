@@ -3,26 +3,82 @@ package sdiscovery
 import (
 	"errors"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/nathan-osman/go-sdiscovery/comm"
+	"github.com/nathan-osman/go-sdiscovery/metrics"
 	"github.com/nathan-osman/go-sdiscovery/peer"
+	"github.com/nathan-osman/go-sdiscovery/store"
+	"github.com/nathan-osman/go-sdiscovery/util"
 )
 
 type peerMap map[string]*peer.Peer
 
+// Number of errors that can be queued before new ones are dropped.
+const errorBufferSize = 16
+
+// Maximum age of peers restored from the store if none was provided.
+const defaultStoreMaxAge = time.Hour
+
 // ServiceConfig contains the parameters that control how the service behaves.
-// Note that it is important to keep the size of UserData to a minimum since
-// the entire struct is sent in each packet. Any modifications to this struct
-// after passing it to New() will be ignored.
+// Note that it is important to keep the size of UserData and Attributes to a
+// minimum since the entire struct is sent in each packet. Any modifications to
+// this struct after passing it to New() will be ignored.
+//
+// Attributes are similar to DNS-SD TXT records and provide a structured
+// alternative to UserData. If AttributeFilter is set, only peers whose
+// attributes contain every key/value pair in the filter are tracked; packets
+// from all other peers are ignored.
+//
+// If Transport is nil, a Communicator using PollInterval and Port is created.
+// Otherwise, the transport is used for sending and receiving packets and will
+// be closed when the service is stopped. If Clock is nil, util.SystemClock is
+// used for all timers. Modes, InterfaceModes and Multicast are passed to the
+// Communicator and determine the types of packets used on each interface.
+// InterfaceFilter restricts the interfaces that the Communicator uses.
+//
+// If LocalHost is set, multicast loopback is enabled where the platform
+// supports it and a LocalTransport is used alongside the Communicator so that
+// other processes on the same machine are discovered. LocalDir is the
+// directory used for the Unix sockets and defaults to
+// comm.DefaultLocalDir(Port). LocalHost has no effect if Transport is set.
+//
+// Sniff is passed to the Communicator and receives every datagram, which
+// allows traffic to be recorded with capture.Writer. It has no effect if
+// Transport is set.
+//
+// If Store is set, the peers it contains are restored when the service starts
+// and are sent over PeerAdded as usual. Peers last seen more than StoreMaxAge
+// ago are ignored; a zero value selects one hour. Restored peers are stale
+// until a packet is received from them and are removed after PeerTimeout
+// otherwise. Stale peers keep the time they were last seen. The peers are
+// saved when they change and always when the service stops, so that the time
+// each peer was last seen is current.
 type ServiceConfig struct {
-	PollInterval time.Duration // time between polling for network interfaces
-	PingInterval time.Duration // time between pings on the network
-	PeerTimeout  time.Duration // time after which a peer is considered unreachable
-	Port         int           // port used for broadcast and multicast
-	ID           string        // unique identifier for the current machine
-	UserData     []byte        // data sent with each packet to other peers
+	PollInterval    time.Duration        // time between polling for network interfaces
+	PingInterval    time.Duration        // time between pings on the network
+	PeerTimeout     time.Duration        // time after which a peer is considered unreachable
+	Port            int                  // port used for broadcast and multicast
+	ID              string               // unique identifier for the current machine
+	UserData        []byte               // data sent with each packet to other peers
+	Attributes      map[string]string    // key/value attributes sent with each packet
+	AttributeFilter map[string]string    // attributes that peers must have to be tracked
+	Transport       comm.Transport       // mechanism for sending and receiving packets
+	Clock           util.Clock           // source of time for pings and timeouts
+	Modes           comm.Mode            // types of packets used on interfaces
+	InterfaceModes  map[string]comm.Mode // types of packets used on specific interfaces
+	Multicast       comm.MulticastConfig // parameters for multicast connections
+	InterfaceFilter comm.InterfaceFilter // determines which interfaces are used
+	LocalHost       bool                 // discover peers on the same machine
+	LocalDir        string               // directory for same-host sockets
+	Logger          util.Logger          // destination for diagnostic messages
+	Metrics         metrics.Metrics      // destination for measurements
+	Hook            Hook                 // receives lifecycle events
+	Sniff           func(*comm.Datagram) // receives every datagram received
+	Store           store.Store          // persists peers across restarts
+	StoreMaxAge     time.Duration        // maximum age of peers restored from the store
 }
 
 // Service sends and receives packets on local network interfaces in order to
@@ -31,7 +87,14 @@ type Service struct {
 	PeerAdded   chan string // indicates that a new peer was found
 	PeerRemoved chan string // indicates that an existing peer has timed out
 	stopChan    chan interface{}
+	stopOnce    sync.Once
+	changeChan  chan struct{}
+	errorChan   chan error
 	peers       peerMap
+	removed     map[string]time.Time
+	transport   comm.Transport
+	interfaces  map[string]bool
+	dirty       bool
 	mutex       sync.Mutex
 	config      ServiceConfig
 }
@@ -39,11 +102,68 @@ type Service struct {
 // Create a new Service instance with the specified configuration.
 func New(config ServiceConfig) *Service {
 
+	// Copy the slices and maps so that later changes by the caller are
+	// ignored.
+	config.UserData = util.CopyBytes(config.UserData)
+	config.Attributes = util.CopyStringMap(config.Attributes)
+	config.AttributeFilter = util.CopyStringMap(config.AttributeFilter)
+	if config.InterfaceModes != nil {
+		modes := make(map[string]comm.Mode, len(config.InterfaceModes))
+		for name, mode := range config.InterfaceModes {
+			modes[name] = mode
+		}
+		config.InterfaceModes = modes
+	}
+	config.Multicast.GroupIPv4 = util.CopyIP(config.Multicast.GroupIPv4)
+	config.Multicast.GroupIPv6 = util.CopyIP(config.Multicast.GroupIPv6)
+	config.InterfaceFilter.Include = util.CopyStrings(config.InterfaceFilter.Include)
+	config.InterfaceFilter.Exclude = util.CopyStrings(config.InterfaceFilter.Exclude)
+	if config.InterfaceFilter.Networks != nil {
+		networks := make([]*net.IPNet, len(config.InterfaceFilter.Networks))
+		for i, network := range config.InterfaceFilter.Networks {
+			networks[i] = &net.IPNet{
+				IP:   util.CopyIP(network.IP),
+				Mask: net.IPMask(util.CopyBytes(network.Mask)),
+			}
+		}
+		config.InterfaceFilter.Networks = networks
+	}
+
+	// Use the system clock if none was provided.
+	if config.Clock == nil {
+		config.Clock = util.SystemClock
+	}
+
+	// Use the default logger if none was provided.
+	if config.Logger == nil {
+		config.Logger = util.DefaultLogger
+	}
+
+	// Discard measurements if there is nowhere to record them.
+	if config.Metrics == nil {
+		config.Metrics = metrics.Discard
+	}
+
+	// Same-host discovery relies on multicast packets being looped back
+	// where the platform allows it. Otherwise, only the Unix sockets are
+	// used.
+	if config.LocalHost {
+		if comm.MulticastOptionsSupported {
+			config.Multicast.Loopback = true
+		} else {
+			config.Logger.Warn("multicast loopback is not supported on this platform")
+		}
+	}
+
 	s := &Service{
 		PeerAdded:   make(chan string),
 		PeerRemoved: make(chan string),
 		stopChan:    make(chan interface{}),
+		changeChan:  make(chan struct{}),
+		errorChan:   make(chan error, errorBufferSize),
 		peers:       make(peerMap),
+		removed:     make(map[string]time.Time),
+		interfaces:  make(map[string]bool),
 		config:      config,
 	}
 
@@ -53,36 +173,111 @@ func New(config ServiceConfig) *Service {
 	return s
 }
 
+// Queue an error for the receiver of Errors(), dropping it if the queue is
+// full.
+func (s *Service) reportError(err *comm.Error) {
+	var ip net.IP
+	if addr, ok := err.Addr.(*net.UDPAddr); ok {
+		ip = addr.IP
+	}
+	s.emit(Event{Type: EventError, Interface: err.Interface, Addr: ip, Err: err})
+	select {
+	case s.errorChan <- err:
+	default:
+	}
+}
+
+// Combine the transport with a LocalTransport for same-host discovery. If the
+// LocalTransport cannot be created, the original transport is returned.
+func (s *Service) localTransport(transport comm.Transport) comm.Transport {
+	dir := s.config.LocalDir
+	if dir == "" {
+		dir = comm.DefaultLocalDir(s.config.Port)
+	}
+	local, err := comm.NewLocalTransport(dir)
+	if err != nil {
+		s.config.Logger.Warn("unable to create local transport", "dir", dir, "error", err)
+		s.reportError(&comm.Error{Kind: comm.ErrInterface, Interface: comm.LocalInterface, Err: err})
+		return transport
+	}
+	return comm.NewMultiTransport(transport, local)
+}
+
 // Process pings and expire peers.
 func (s *Service) run() {
 
-	// Create a communicator for sending and receiving packets.
-	communicator := comm.NewCommunicator(s.config.PollInterval, s.config.Port)
-	defer communicator.Stop()
+	// Use the transport provided or create a communicator for sending and
+	// receiving packets.
+	transport := s.config.Transport
+	if transport == nil {
+		transport = comm.NewCommunicatorWithConfig(comm.CommunicatorConfig{
+			PollInterval:   s.config.PollInterval,
+			Port:           s.config.Port,
+			Clock:          s.config.Clock,
+			Modes:          s.config.Modes,
+			InterfaceModes: s.config.InterfaceModes,
+			Multicast:      s.config.Multicast,
+			Filter:         s.config.InterfaceFilter,
+			Logger:         s.config.Logger,
+			OnError:        s.reportError,
+			Metrics:        s.config.Metrics,
+			Sniff:          s.config.Sniff,
+		})
+		if s.config.LocalHost {
+			transport = s.localTransport(transport)
+		}
+	}
+	defer transport.Close()
+
+	// Keep track of the transport so that its connections can be listed.
+	s.mutex.Lock()
+	s.transport = transport
+	s.mutex.Unlock()
+
+	// Restore the peers that were known when the service last stopped and
+	// save them again when it stops.
+	if s.config.Store != nil {
+		s.loadPeers()
+		defer s.savePeers(true)
+	}
 
 	// Create a ticker for sending pings.
-	pingTicker := time.NewTicker(s.config.PingInterval)
+	pingTicker := s.config.Clock.NewTicker(s.config.PingInterval)
 	defer pingTicker.Stop()
 
 	// Create a ticker for timeout checks.
-	peerTicker := time.NewTicker(s.config.PeerTimeout)
+	peerTicker := s.config.Clock.NewTicker(s.config.PeerTimeout)
 	defer peerTicker.Stop()
 
 	// Create the packet that will be sent to all peers.
 	pkt := &comm.Packet{
-		ID:       s.config.ID,
-		UserData: s.config.UserData,
+		ID:         s.config.ID,
+		UserData:   s.config.UserData,
+		Attributes: s.config.Attributes,
 	}
 
 	// Continue processing events until explicitly stopped.
 	for {
 		select {
-		case p := <-communicator.PacketChan:
+		case p, ok := <-transport.Receive():
+			if !ok {
+				s.Stop()
+				return
+			}
 			s.processPacket(p)
-		case <-pingTicker.C:
-			communicator.Send(pkt)
-		case <-peerTicker.C:
+		case e, ok := <-transport.Events():
+			if !ok {
+				s.Stop()
+				return
+			}
+			s.processEvent(e)
+		case <-pingTicker.C():
+			transport.Send(pkt)
+		case <-peerTicker.C():
 			s.processPeers()
+			if s.config.Store != nil {
+				s.savePeers(false)
+			}
 		case <-s.stopChan:
 			return
 		}
@@ -92,53 +287,151 @@ func (s *Service) run() {
 // Process a packet received from one of the connections.
 func (s *Service) processPacket(pkt *comm.Packet) {
 
-	// Obtain exclusive access to the map.
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	// Check the ID on the packet to ensure it does not match this peer and
+	// that the peer has the attributes required by the filter.
+	if pkt.ID == s.config.ID {
+		s.config.Metrics.PacketDropped(pkt.Interface, pkt.Type, "self")
+		return
+	}
+	if !matchAttributes(pkt.Attributes, s.config.AttributeFilter) {
+		s.config.Metrics.PacketDropped(pkt.Interface, pkt.Type, "filter")
+		return
+	}
 
-	// Check the ID on the packet to ensure it does not match this peer.
-	if pkt.ID != s.config.ID {
+	// Obtain exclusive access to the map while it is updated.
+	s.mutex.Lock()
 
-		// If the peer ID is not in the map, then create a new one.
-		_, exists := s.peers[pkt.ID]
-		if !exists {
-			s.peers[pkt.ID] = &peer.Peer{}
+	// If the peer ID is not in the map, then create a new one. If the peer
+	// was removed recently, it has flapped.
+	curTime := s.config.Clock.Now()
+	_, exists := s.peers[pkt.ID]
+	if !exists {
+		s.peers[pkt.ID] = &peer.Peer{}
+		if t, ok := s.removed[pkt.ID]; ok && curTime.Sub(t) <= s.config.PeerTimeout {
+			s.config.Metrics.PeerFlapped(pkt.ID)
 		}
+		delete(s.removed, pkt.ID)
+		s.config.Metrics.PeerAdded(pkt.ID)
+		s.config.Metrics.PeerCount(len(s.peers))
+	}
 
-		// Update the peer with the packet that was received.
-		s.peers[pkt.ID].Ping(pkt, time.Now())
+	// Update the peer with the packet that was received and wake anything
+	// waiting for changes to the map.
+	updated := exists && s.peers[pkt.ID].Changed(pkt)
+	s.peers[pkt.ID].Ping(pkt, curTime)
+	s.config.Metrics.PeerSeen(pkt.ID, curTime)
+	s.notifyChange()
+	if !exists || updated {
+		s.dirty = true
+	}
 
-		// If the peer didn't exist in the map prior to this packet, then send
-		// the peer ID over the PeerAdded channel.
-		if !exists {
-			s.PeerAdded <- pkt.ID
+	s.mutex.Unlock()
+
+	// Notify the hook of any change to the peer and then of the packet, so
+	// that the packet that adds a peer follows the addition.
+	e := Event{
+		Time:      curTime,
+		PeerID:    pkt.ID,
+		Interface: pkt.Interface,
+		Addr:      pkt.IP,
+	}
+	if !exists {
+		e.Type = EventPeerAdded
+		s.emit(e)
+	} else if updated {
+		e.Type = EventPeerUpdated
+		s.emit(e)
+	}
+	e.Type = EventPacketReceived
+	s.emit(e)
+
+	// If the peer didn't exist in the map prior to this packet, then send
+	// the peer ID over the PeerAdded channel. This is done without holding
+	// the mutex so that other methods can be used while the send blocks.
+	if !exists {
+		s.config.Logger.Debug("peer added", "peer", pkt.ID, "interface", pkt.Interface, "addr", pkt.IP)
+		select {
+		case s.PeerAdded <- pkt.ID:
+		case <-s.stopChan:
 		}
 	}
 }
 
+// Keep track of the interfaces in use by the transport.
+func (s *Service) processEvent(e comm.InterfaceEvent) {
+
+	// Obtain exclusive access to the map while it is updated.
+	s.mutex.Lock()
+	if e.Added {
+		s.interfaces[e.Name] = true
+	} else {
+		delete(s.interfaces, e.Name)
+	}
+	s.mutex.Unlock()
+
+	// Notify the hook without holding the mutex.
+	if e.Added {
+		s.emit(Event{Type: EventInterfaceAdded, Interface: e.Name})
+	} else {
+		s.emit(Event{Type: EventInterfaceRemoved, Interface: e.Name})
+	}
+}
+
 // Check each of the peers in order to determine if any expired.
 func (s *Service) processPeers() {
 
-	// Obtain exclusive access to the map.
+	// Obtain exclusive access to the map while it is updated.
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
 
-	// Avoid repeated calls to time.Now() by invoking it once here.
-	curTime := time.Now()
+	// Avoid repeated calls to Now() by invoking it once here.
+	curTime := s.config.Clock.Now()
 
+	// Remove expired peers, keeping track of their IDs.
+	var removed []string
 	for id, peer := range s.peers {
 		if peer.IsExpired(s.config.PeerTimeout, curTime) {
-
-			// Send the peer ID over the PeerRemoved channel and remove it.
-			s.PeerRemoved <- id
+			removed = append(removed, id)
 			delete(s.peers, id)
+			s.removed[id] = curTime
+			s.config.Metrics.PeerRemoved(id)
 		}
 	}
+	if len(removed) != 0 {
+		s.config.Metrics.PeerCount(len(s.peers))
+		s.notifyChange()
+		s.dirty = true
+	}
+
+	// Forget peers that were removed too long ago to be considered flapping.
+	for id, t := range s.removed {
+		if curTime.Sub(t) > s.config.PeerTimeout {
+			delete(s.removed, id)
+		}
+	}
+
+	s.mutex.Unlock()
+
+	// Send each of the peer IDs over the PeerRemoved channel.
+	for _, id := range removed {
+		s.config.Logger.Debug("peer removed", "peer", id)
+		s.emit(Event{Type: EventPeerRemoved, Time: curTime, PeerID: id})
+		select {
+		case s.PeerRemoved <- id:
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// Wake all goroutines waiting for changes to the map. The map must be locked.
+func (s *Service) notifyChange() {
+	close(s.changeChan)
+	s.changeChan = make(chan struct{})
 }
 
 // Obtain a sorted slice of IP addresses to use for connecting to the specified
 // peer. The first IP address is the one that has received the most packets
-// recently.
+// recently. The addresses returned are copies and may be freely modified.
 func (s *Service) PeerAddrs(id string) ([]net.IP, error) {
 
 	// Obtain exclusive access to the map.
@@ -154,7 +447,8 @@ func (s *Service) PeerAddrs(id string) ([]net.IP, error) {
 	return p.Addrs(), nil
 }
 
-// Obtain the custom user data provided by the specified peer.
+// Obtain the custom user data provided by the specified peer. The slice
+// returned is a copy and may be freely modified.
 func (s *Service) PeerUserData(id string) ([]byte, error) {
 
 	// Obtain exclusive access to the map.
@@ -167,11 +461,73 @@ func (s *Service) PeerUserData(id string) ([]byte, error) {
 		return nil, errors.New("Peer does not exist")
 	}
 
-	return p.UserData, nil
+	return p.UserData(), nil
+}
+
+// Obtain the key/value attributes provided by the specified peer. The map
+// returned is a copy and may be freely modified.
+func (s *Service) PeerAttributes(id string) (map[string]string, error) {
+
+	// Obtain exclusive access to the map.
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Attempt to retrieve the peer from the map.
+	p, exists := s.peers[id]
+	if !exists {
+		return nil, errors.New("Peer does not exist")
+	}
+
+	return p.Attributes(), nil
+}
+
+// Obtain a sorted list of the network interfaces currently in use by the
+// transport.
+func (s *Service) Interfaces() []string {
+
+	// Obtain exclusive access to the map.
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	names := make([]string, 0, len(s.interfaces))
+	for name := range s.interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Obtain a description of each connection used by the transport. Nil is
+// returned if the transport does not implement comm.ConnectionLister.
+func (s *Service) Connections() []comm.ConnectionInfo {
+
+	// Obtain the transport while holding the mutex but list the connections
+	// without it, since the transport may block.
+	s.mutex.Lock()
+	transport := s.transport
+	s.mutex.Unlock()
+
+	if l, ok := transport.(comm.ConnectionLister); ok {
+		return l.Connections()
+	}
+	return nil
+}
+
+// Obtain a channel that receives problems that degrade discovery, such as
+// malformed packets and failing sockets. Each error is a *comm.Error whose
+// kind can be tested with errors.Is. Errors are only reported when the
+// service creates its own Communicator. If errors are not received promptly,
+// new ones are dropped.
+func (s *Service) Errors() <-chan error {
+	return s.errorChan
 }
 
 // Stop the service. No more packets will be sent or received and all
-// connections will be closed.
+// connections will be closed. The service also stops itself if the transport
+// closes its channels, so it is safe to call this more than once.
 func (s *Service) Stop() {
-	close(s.stopChan)
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+	})
 }
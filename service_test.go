@@ -1,8 +1,20 @@
 package sdiscovery
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/comm"
+	"github.com/nathan-osman/go-sdiscovery/comm/commtest"
+	"github.com/nathan-osman/go-sdiscovery/metrics"
+	"github.com/nathan-osman/go-sdiscovery/store"
+	"github.com/nathan-osman/go-sdiscovery/util"
 )
 
 // Ensure that the Service class can be instantiated and terminated.
@@ -16,3 +28,442 @@ func Test_Service(t *testing.T) {
 	})
 	defer s.Stop()
 }
+
+// Transport that uses channels directly so that tests can interact with it.
+type chanTransport struct {
+	sendChan    chan *comm.Packet
+	receiveChan chan *comm.Packet
+	eventChan   chan comm.InterfaceEvent
+	closeChan   chan interface{}
+}
+
+func (c *chanTransport) Send(pkt *comm.Packet) {
+	select {
+	case c.sendChan <- pkt:
+	default:
+	}
+}
+func (c *chanTransport) Receive() <-chan *comm.Packet       { return c.receiveChan }
+func (c *chanTransport) Events() <-chan comm.InterfaceEvent { return c.eventChan }
+func (c *chanTransport) Close()                             { close(c.closeChan) }
+
+// Ensure that the service uses the transport provided in its configuration.
+func Test_Service_Transport(t *testing.T) {
+
+	tr := &chanTransport{
+		sendChan:    make(chan *comm.Packet, 1),
+		receiveChan: make(chan *comm.Packet),
+		eventChan:   make(chan comm.InterfaceEvent),
+		closeChan:   make(chan interface{}),
+	}
+	s := New(ServiceConfig{
+		PollInterval: time.Second,
+		PingInterval: 10 * time.Millisecond,
+		PeerTimeout:  time.Second,
+		ID:           "1234",
+		Transport:    tr,
+	})
+
+	// Ensure the service pings using the transport.
+	select {
+	case pkt := <-tr.sendChan:
+		if pkt.ID != "1234" {
+			t.Fatal("Incorrect ID in ping")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No ping was sent")
+	}
+
+	// Ensure interface events are recorded.
+	tr.eventChan <- comm.InterfaceEvent{Name: "test0", Added: true}
+	tr.receiveChan <- &comm.Packet{ID: "5678"}
+	if names := s.Interfaces(); len(names) != 1 || names[0] != "test0" {
+		t.Fatal("Interface was not recorded")
+	}
+
+	// Ensure that received packets result in a new peer.
+	if id := <-s.PeerAdded; id != "5678" {
+		t.Fatal("Incorrect peer added")
+	}
+
+	// Ensure that the transport is closed when the service is stopped.
+	s.Stop()
+	select {
+	case <-tr.closeChan:
+	case <-time.After(time.Second):
+		t.Fatal("Transport was not closed")
+	}
+}
+
+// Ensure that the service stops when the transport closes its channels.
+func Test_Service_TransportClosed(t *testing.T) {
+
+	tr := &chanTransport{
+		sendChan:    make(chan *comm.Packet, 1),
+		receiveChan: make(chan *comm.Packet),
+		eventChan:   make(chan comm.InterfaceEvent),
+		closeChan:   make(chan interface{}),
+	}
+	s := New(ServiceConfig{
+		PollInterval: time.Second,
+		PingInterval: time.Second,
+		PeerTimeout:  time.Second,
+		ID:           "1234",
+		Transport:    tr,
+	})
+	defer s.Stop()
+
+	// Close the channels and ensure that waiting is interrupted.
+	close(tr.receiveChan)
+	close(tr.eventChan)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := s.WaitForPeer(ctx, nil); err != ErrServiceStopped {
+		t.Fatalf("Expected ErrServiceStopped, got %v", err)
+	}
+}
+
+// Wait for the specified IDs to be sent on each of the channels. The values
+// are received in any order since each service blocks until its value is
+// received.
+func waitForIDs(t *testing.T, c1 chan string, id1 string, c2 chan string, id2 string) {
+	timeout := time.After(time.Second)
+	for c1 != nil || c2 != nil {
+		select {
+		case v := <-c1:
+			if v != id1 {
+				t.Fatalf("Expected %s, received %s", id1, v)
+			}
+			c1 = nil
+		case v := <-c2:
+			if v != id2 {
+				t.Fatalf("Expected %s, received %s", id2, v)
+			}
+			c2 = nil
+		case <-timeout:
+			t.Fatal("Timed out waiting for peers")
+		}
+	}
+}
+
+// Ensure that peers on a simulated network discover each other and time out
+// when they can no longer communicate.
+func Test_Service_Discovery(t *testing.T) {
+
+	c := util.NewFakeClock(time.Unix(0, 0))
+	n := commtest.NewNetwork(0)
+	e := metrics.NewExporter(c)
+	newService := func(id string, m metrics.Metrics) *Service {
+		return New(ServiceConfig{
+			PingInterval: time.Second,
+			PeerTimeout:  4 * time.Second,
+			ID:           id,
+			Transport:    n.NewNode(id, "eth0"),
+			Clock:        c,
+			Metrics:      m,
+		})
+	}
+	a := newService("a", e)
+	defer a.Stop()
+	b := newService("b", nil)
+	defer b.Stop()
+
+	// Wait for both services to create their tickers and then ping.
+	c.BlockUntil(4)
+	c.Advance(time.Second)
+
+	// Each of the services should discover the other.
+	waitForIDs(t, a.PeerAdded, "b", b.PeerAdded, "a")
+
+	// Partitioning the services should cause them to time out.
+	n.Partition("a", "b")
+	c.Advance(8 * time.Second)
+	waitForIDs(t, a.PeerRemoved, "b", b.PeerRemoved, "a")
+
+	// Healing the partition should cause them to be rediscovered.
+	n.Heal("a", "b")
+	c.Advance(time.Second)
+	waitForIDs(t, a.PeerAdded, "b", b.PeerAdded, "a")
+
+	// The rediscovery should be recorded as a flap.
+	var buf bytes.Buffer
+	e.WriteTo(&buf)
+	for _, line := range []string{
+		"sdiscovery_peers 1\n",
+		"sdiscovery_peers_added_total 2\n",
+		"sdiscovery_peers_removed_total 1\n",
+		"sdiscovery_peer_flaps_total 1\n",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Fatalf("Missing %q", line)
+		}
+	}
+}
+
+// Ensure that two services on the same machine discover each other using only
+// the Unix socket rendezvous.
+func Test_Service_LocalHost(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sdiscovery")
+	newService := func(id string) *Service {
+		return New(ServiceConfig{
+			PollInterval:    time.Second,
+			PingInterval:    50 * time.Millisecond,
+			PeerTimeout:     time.Second,
+			Port:            8003,
+			ID:              id,
+			InterfaceFilter: comm.InterfaceFilter{Include: []string{"-"}},
+			LocalHost:       true,
+			LocalDir:        dir,
+		})
+	}
+	s1 := newService("1")
+	defer s1.Stop()
+	s2 := newService("2")
+	defer s2.Stop()
+	waitForIDs(t, s1.PeerAdded, "2", s2.PeerAdded, "1")
+	if addrs, err := s1.PeerAddrs("2"); err != nil || len(addrs) != 1 || !addrs[0].IsLoopback() {
+		t.Fatal("Peer address is incorrect")
+	}
+}
+
+// Ensure that errors are queued until the buffer is full and then dropped.
+func Test_Service_Errors(t *testing.T) {
+	s := &Service{errorChan: make(chan error, errorBufferSize)}
+	for i := 0; i < errorBufferSize+1; i++ {
+		s.reportError(&comm.Error{Kind: comm.ErrDecode})
+	}
+	if len(s.Errors()) != errorBufferSize {
+		t.Fatal("Errors were not queued")
+	}
+	if err := <-s.Errors(); !errors.Is(err, comm.ErrDecode) {
+		t.Fatal("Incorrect error received")
+	}
+}
+
+// Ensure that the hook receives lifecycle events in order.
+func Test_Service_Hook(t *testing.T) {
+
+	c := util.NewFakeClock(time.Unix(0, 0))
+	n := commtest.NewNetwork(0)
+	events := make(chan Event, 100)
+	a := New(ServiceConfig{
+		PingInterval: time.Second,
+		PeerTimeout:  4 * time.Second,
+		ID:           "a",
+		Transport:    n.NewNode("a", "eth0"),
+		Clock:        c,
+		Hook:         HookFunc(func(e Event) { events <- e }),
+	})
+	defer a.Stop()
+	b := New(ServiceConfig{
+		PingInterval: time.Second,
+		PeerTimeout:  4 * time.Second,
+		ID:           "b",
+		Transport:    n.NewNode("b", "eth0"),
+		Clock:        c,
+	})
+	defer b.Stop()
+
+	c.BlockUntil(4)
+	c.Advance(time.Second)
+	waitForIDs(t, a.PeerAdded, "b", b.PeerAdded, "a")
+
+	// Skip the interface event, then expect the addition and the packet.
+	var types []EventType
+	for len(types) < 2 {
+		if e := <-events; e.Type != EventInterfaceAdded {
+			if e.PeerID != "b" || e.Interface != "eth0" {
+				t.Fatal("Event is incorrect")
+			}
+			types = append(types, e.Type)
+		}
+	}
+	if types[0] != EventPeerAdded || types[1] != EventPacketReceived {
+		t.Fatal("Events are in the wrong order")
+	}
+}
+
+// Ensure that peers restored from the store are stale until confirmed, that
+// unconfirmed peers expire and that the store is updated.
+func Test_Service_Store(t *testing.T) {
+
+	// Peer d was last seen too long ago to be restored.
+	lastSeen := time.Unix(99, 0)
+	st := &store.MemoryStore{}
+	st.Save([]store.Peer{
+		{ID: "a", Addrs: []store.Addr{{IP: net.IPv4(10, 0, 0, 1), Interface: "eth0"}}, LastSeen: lastSeen},
+		{ID: "b", Addrs: []store.Addr{{IP: net.IPv4(10, 0, 0, 2), Interface: "eth0"}}, LastSeen: lastSeen},
+		{ID: "c", Addrs: []store.Addr{{IP: net.IPv4(10, 0, 0, 3), Interface: "eth0"}}, LastSeen: lastSeen},
+		{ID: "d", Addrs: []store.Addr{{IP: net.IPv4(10, 0, 0, 4), Interface: "eth0"}}, LastSeen: time.Unix(0, 0)},
+	})
+	c := util.NewFakeClock(time.Unix(100, 0))
+	n := commtest.NewNetwork(0)
+	a := New(ServiceConfig{
+		PingInterval: time.Second,
+		PeerTimeout:  4 * time.Second,
+		ID:           "a",
+		Transport:    n.NewNode("a", "eth0"),
+		Clock:        c,
+		Store:        st,
+		StoreMaxAge:  time.Minute,
+	})
+	defer a.Stop()
+
+	// Both of the other peers should be restored as stale peers.
+	for _, id := range []string{"b", "c"} {
+		if v := <-a.PeerAdded; v != id {
+			t.Fatalf("Expected %s to be restored", id)
+		}
+	}
+	peers := a.Peers()
+	if len(peers) != 2 {
+		t.Fatal("Expected exactly two restored peers")
+	}
+	for _, info := range peers {
+		if !info.Stale || !info.LastSeen.Equal(lastSeen) {
+			t.Fatal("Expected restored peers to be stale")
+		}
+	}
+
+	// Start peer b and wait for it to be confirmed.
+	b := New(ServiceConfig{
+		PingInterval: time.Second,
+		PeerTimeout:  4 * time.Second,
+		ID:           "b",
+		Transport:    n.NewNode("b", "eth0"),
+		Clock:        c,
+	})
+	defer b.Stop()
+	c.BlockUntil(4)
+	c.Advance(time.Second)
+	if v := <-b.PeerAdded; v != "a" {
+		t.Fatal("Expected a to be added")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := a.WaitForPeer(ctx, func(i PeerInfo) bool {
+		return i.ID == "b" && !i.Stale
+	}); err != nil {
+		t.Fatal("Peer b was not confirmed")
+	}
+
+	// Peer c should expire and be removed from the store.
+	c.Advance(3 * time.Second)
+	if v := <-a.PeerRemoved; v != "c" {
+		t.Fatal("Expected c to be removed")
+	}
+	for i := 0; ; i++ {
+		peers, _ := st.Load()
+		if len(peers) == 1 && peers[0].ID == "b" && len(peers[0].Addrs) != 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Store was not updated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Ensure that a peer which only sent pings for longer than the peer timeout
+// is saved with the time it was last seen and restored after a restart.
+func Test_Service_StoreRestart(t *testing.T) {
+
+	st := &store.MemoryStore{}
+	c := util.NewFakeClock(time.Unix(100, 0))
+	n := commtest.NewNetwork(0)
+	config := ServiceConfig{
+		PingInterval: time.Second,
+		PeerTimeout:  4 * time.Second,
+		ID:           "a",
+		Transport:    n.NewNode("a", "eth0"),
+		Clock:        c,
+		Store:        st,
+	}
+	a := New(config)
+	b := New(ServiceConfig{
+		PingInterval: time.Second,
+		PeerTimeout:  4 * time.Second,
+		ID:           "b",
+		Transport:    n.NewNode("b", "eth0"),
+		Clock:        c,
+	})
+	c.BlockUntil(4)
+	c.Advance(time.Second)
+	waitForIDs(t, a.PeerAdded, "b", b.PeerAdded, "a")
+
+	// Continue receiving pings for longer than the peer timeout, waiting for
+	// each one to be recorded.
+	for i := 0; i < 6; i++ {
+		c.Advance(time.Second)
+		for j := 0; ; j++ {
+			if peers := a.Peers(); len(peers) == 1 && peers[0].LastSeen.Equal(c.Now()) {
+				break
+			}
+			if j == 100 {
+				t.Fatal("Ping was not recorded")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	b.Stop()
+	a.Stop()
+
+	// The store should contain the time of the most recent ping.
+	for i := 0; ; i++ {
+		peers, _ := st.Load()
+		if len(peers) == 1 && peers[0].LastSeen.Equal(c.Now()) {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Store was not updated when the service stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Restart the service later and ensure the peer is restored.
+	c.Advance(10 * time.Second)
+	config.Transport = n.NewNode("c", "eth0")
+	a = New(config)
+	defer a.Stop()
+	select {
+	case v := <-a.PeerAdded:
+		if v != "b" {
+			t.Fatal("Expected b to be restored")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Peer was not restored")
+	}
+}
+
+// Ensure that changes to the configuration after New() are ignored.
+func Test_Service_Config(t *testing.T) {
+	config := ServiceConfig{
+		PingInterval: time.Second,
+		PeerTimeout:  time.Second,
+		Transport:    commtest.NewNetwork(0).NewNode("a", "eth0"),
+		Multicast: comm.MulticastConfig{
+			GroupIPv4: net.IPv4(239, 255, 1, 1),
+			GroupIPv6: net.ParseIP("ff02::5344"),
+		},
+		InterfaceFilter: comm.InterfaceFilter{
+			Include:  []string{"eth*"},
+			Exclude:  []string{"docker*"},
+			Networks: []*net.IPNet{{IP: net.IPv4(10, 0, 0, 0), Mask: net.CIDRMask(8, 32)}},
+		},
+	}
+	s := New(config)
+	defer s.Stop()
+	config.Multicast.GroupIPv4[15] = 2
+	config.Multicast.GroupIPv6[15] = 0
+	if !s.config.Multicast.GroupIPv4.Equal(net.IPv4(239, 255, 1, 1)) ||
+		!s.config.Multicast.GroupIPv6.Equal(net.ParseIP("ff02::5344")) {
+		t.Fatal("Multicast groups were not copied")
+	}
+	config.InterfaceFilter.Include[0] = "*"
+	config.InterfaceFilter.Exclude[0] = "*"
+	config.InterfaceFilter.Networks[0].IP[15] = 1
+	f := s.config.InterfaceFilter
+	if f.Include[0] != "eth*" || f.Exclude[0] != "docker*" || !f.Networks[0].IP.Equal(net.IPv4(10, 0, 0, 0)) {
+		t.Fatal("Interface filter was not copied")
+	}
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileStore keeps peers in a JSON file. The file is written to disk and then
+// atomically replaced when peers are saved so that a crash or power loss
+// never leaves it partially written.
+type FileStore struct {
+	path string
+}
+
+// Create a new store using the file at the specified path. The file is
+// created when peers are first saved.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path: path,
+	}
+}
+
+// Read the peers from the file. No peers are returned if the file does not
+// exist yet.
+func (f *FileStore) Load() ([]Peer, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var peers []Peer
+	if err := json.Unmarshal(data, &peers); err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+// Write the peers to a temporary file in the same directory, flush it to disk
+// and then rename it over the original.
+func (f *FileStore) Save(peers []Peer) error {
+	if peers == nil {
+		peers = []Peer{}
+	}
+	data, err := json.MarshalIndent(peers, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return err
+	}
+
+	// Flush the directory so that the rename itself is durable. Not all
+	// platforms support this, so failures are ignored.
+	if dir, err := os.Open(filepath.Dir(f.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
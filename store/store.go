@@ -0,0 +1,77 @@
+package store
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Addr is an address from which packets were received and the interface on
+// which they arrived.
+type Addr struct {
+	IP        net.IP `json:"ip"`
+	Interface string `json:"interface"`
+}
+
+// Peer is the last-known state of a peer.
+type Peer struct {
+	ID         string            `json:"id"`
+	UserData   []byte            `json:"user_data,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Addrs      []Addr            `json:"addrs"`
+	LastSeen   time.Time         `json:"last_seen"`
+}
+
+// Copy the peer so that it shares no memory with the original.
+func (p Peer) copy() Peer {
+	addrs := make([]Addr, len(p.Addrs))
+	for i, addr := range p.Addrs {
+		addrs[i] = Addr{
+			IP:        util.CopyIP(addr.IP),
+			Interface: addr.Interface,
+		}
+	}
+	p.UserData = util.CopyBytes(p.UserData)
+	p.Attributes = util.CopyStringMap(p.Attributes)
+	p.Addrs = addrs
+	return p
+}
+
+// Store persists peers so that they are available after a restart. Load is
+// invoked once when the service starts and Save each time the peers change
+// and when the service stops. Save replaces everything stored previously.
+type Store interface {
+	Load() ([]Peer, error)
+	Save(peers []Peer) error
+}
+
+// MemoryStore keeps peers in memory, which is useful for tests and for
+// services that are stopped and recreated within a single process.
+type MemoryStore struct {
+	mutex sync.Mutex
+	peers []Peer
+}
+
+// Obtain copies of the peers that were saved.
+func (m *MemoryStore) Load() ([]Peer, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	peers := make([]Peer, len(m.peers))
+	for i, p := range m.peers {
+		peers[i] = p.copy()
+	}
+	return peers, nil
+}
+
+// Replace the stored peers with copies of those provided.
+func (m *MemoryStore) Save(peers []Peer) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.peers = make([]Peer, len(peers))
+	for i, p := range peers {
+		m.peers[i] = p.copy()
+	}
+	return nil
+}
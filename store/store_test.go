@@ -0,0 +1,80 @@
+package store
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testPeer = Peer{
+	ID:         "a",
+	UserData:   []byte("data"),
+	Attributes: map[string]string{"role": "db"},
+	Addrs: []Addr{
+		{IP: net.IPv4(192, 168, 1, 1), Interface: "eth0"},
+	},
+	LastSeen: time.Unix(100, 0).UTC(),
+}
+
+// Ensure that the peers loaded match those saved.
+func checkPeers(t *testing.T, s Store) {
+	if err := s.Save([]Peer{testPeer}); err != nil {
+		t.Fatal(err)
+	}
+	peers, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 1 {
+		t.Fatal("Expected exactly one peer")
+	}
+	p := peers[0]
+	if p.ID != "a" || string(p.UserData) != "data" || p.Attributes["role"] != "db" ||
+		!p.LastSeen.Equal(testPeer.LastSeen) {
+		t.Fatal("Peer is incorrect")
+	}
+	if len(p.Addrs) != 1 || !p.Addrs[0].IP.Equal(testPeer.Addrs[0].IP) || p.Addrs[0].Interface != "eth0" {
+		t.Fatal("Addresses are incorrect")
+	}
+}
+
+// Ensure that the memory store returns copies of the peers.
+func Test_MemoryStore(t *testing.T) {
+	s := &MemoryStore{}
+	checkPeers(t, s)
+	peers, _ := s.Load()
+	peers[0].Attributes["role"] = "web"
+	peers[0].Addrs[0].IP[0] = 0
+	checkPeers(t, s)
+}
+
+// Ensure that peers survive being written to a file and that a missing file
+// is treated as empty.
+func Test_FileStore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "peers.json")
+	s := NewFileStore(path)
+	if peers, err := s.Load(); err != nil || len(peers) != 0 {
+		t.Fatal("Expected no peers")
+	}
+	checkPeers(t, NewFileStore(path))
+
+	// No temporary files should remain.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatal("Temporary file was not removed")
+	}
+
+	// Saving no peers should leave an empty list.
+	if err := s.Save(nil); err != nil {
+		t.Fatal(err)
+	}
+	if peers, err := s.Load(); err != nil || len(peers) != 0 {
+		t.Fatal("Expected no peers")
+	}
+}
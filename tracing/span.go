@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"sync"
+	"time"
+)
+
+// SpanEvent is a single occurrence recorded within a span.
+type SpanEvent struct {
+	Name       string            // type of event
+	Time       time.Time         // when the event occurred
+	Attributes map[string]string // details of the event
+}
+
+// Span covers the lifetime of a peer or interface. Events that occur while
+// the peer or interface exists are recorded within it.
+type Span struct {
+	Name       string            // "peer" or "interface"
+	Attributes map[string]string // identifies the peer or interface
+	Start      time.Time         // when the peer or interface was added
+	End        time.Time         // when it was removed (zero if still open)
+	Events     []SpanEvent       // events that occurred during the span
+}
+
+// Exporter receives spans once they have ended.
+type Exporter interface {
+	ExportSpan(s *Span)
+}
+
+// InMemoryExporter stores spans in memory so that they can be inspected, such
+// as in tests.
+type InMemoryExporter struct {
+	mutex sync.Mutex
+	spans []*Span
+}
+
+// Store the span.
+func (i *InMemoryExporter) ExportSpan(s *Span) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.spans = append(i.spans, s)
+}
+
+// Obtain the spans that have been exported, in the order they ended.
+func (i *InMemoryExporter) Spans() []*Span {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return append([]*Span{}, i.spans...)
+}
+
+// Remove all spans that have been exported.
+func (i *InMemoryExporter) Reset() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.spans = nil
+}
@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/nathan-osman/go-sdiscovery"
+)
+
+// Maximum number of events recorded on a single span. Later events are
+// counted in the "dropped_events" attribute instead so that long-lived spans
+// do not grow without bound.
+const maxSpanEvents = 100
+
+// Tracer is a Hook that records the lifecycle of each peer and interface as a
+// span. A peer span starts when the peer is added and ends when it is
+// removed, with updates recorded as events and packets counted in the
+// "packets" attribute. Errors are recorded on the span for their interface,
+// or as a span of their own if the interface is unknown.
+type Tracer struct {
+	mutex      sync.Mutex
+	exporter   Exporter
+	peers      map[string]*Span
+	packets    map[string]int
+	interfaces map[string]*Span
+}
+
+// Create a new tracer that sends spans to the exporter as they end.
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{
+		exporter:   exporter,
+		peers:      make(map[string]*Span),
+		packets:    make(map[string]int),
+		interfaces: make(map[string]*Span),
+	}
+}
+
+// Record an event on the span unless it already holds the maximum number of
+// events, in which case the event is only counted.
+func addEvent(s *Span, e SpanEvent) {
+	if len(s.Events) < maxSpanEvents {
+		s.Events = append(s.Events, e)
+		return
+	}
+	n, _ := strconv.Atoi(s.Attributes["dropped_events"])
+	s.Attributes["dropped_events"] = strconv.Itoa(n + 1)
+}
+
+// Remove the span for the peer, recording the number of packets received.
+// The tracer must be locked.
+func (t *Tracer) endPeer(id string) *Span {
+	s := t.peers[id]
+	s.Attributes["packets"] = strconv.Itoa(t.packets[id])
+	delete(t.peers, id)
+	delete(t.packets, id)
+	return s
+}
+
+// Create an event describing the service event.
+func newSpanEvent(e sdiscovery.Event) SpanEvent {
+	attrs := make(map[string]string)
+	if e.Interface != "" {
+		attrs["interface"] = e.Interface
+	}
+	if e.Addr != nil {
+		attrs["addr"] = e.Addr.String()
+	}
+	if e.Err != nil {
+		attrs["error"] = e.Err.Error()
+	}
+	return SpanEvent{
+		Name:       e.Type.String(),
+		Time:       e.Time,
+		Attributes: attrs,
+	}
+}
+
+// Record the event in the appropriate span.
+func (t *Tracer) HandleEvent(e sdiscovery.Event) {
+
+	// Obtain exclusive access to the spans. Spans that end are exported
+	// after the mutex is released.
+	t.mutex.Lock()
+	var ended *Span
+	switch e.Type {
+	case sdiscovery.EventPeerAdded:
+		t.peers[e.PeerID] = &Span{
+			Name:       "peer",
+			Attributes: map[string]string{"peer": e.PeerID},
+			Start:      e.Time,
+			Events:     []SpanEvent{newSpanEvent(e)},
+		}
+	case sdiscovery.EventPacketReceived:
+		if _, ok := t.peers[e.PeerID]; ok {
+			t.packets[e.PeerID]++
+		}
+	case sdiscovery.EventPeerUpdated:
+		if s, ok := t.peers[e.PeerID]; ok {
+			addEvent(s, newSpanEvent(e))
+		}
+	case sdiscovery.EventPeerRemoved:
+		if s, ok := t.peers[e.PeerID]; ok {
+			addEvent(s, newSpanEvent(e))
+			s.End = e.Time
+			ended = t.endPeer(e.PeerID)
+		}
+	case sdiscovery.EventInterfaceAdded:
+		t.interfaces[e.Interface] = &Span{
+			Name:       "interface",
+			Attributes: map[string]string{"interface": e.Interface},
+			Start:      e.Time,
+		}
+	case sdiscovery.EventInterfaceRemoved:
+		if s, ok := t.interfaces[e.Interface]; ok {
+			s.End = e.Time
+			delete(t.interfaces, e.Interface)
+			ended = s
+		}
+	case sdiscovery.EventError:
+		if s, ok := t.interfaces[e.Interface]; ok {
+			addEvent(s, newSpanEvent(e))
+		} else {
+			ended = &Span{
+				Name:       "error",
+				Attributes: map[string]string{},
+				Start:      e.Time,
+				End:        e.Time,
+				Events:     []SpanEvent{newSpanEvent(e)},
+			}
+		}
+	}
+	t.mutex.Unlock()
+
+	if ended != nil {
+		t.exporter.ExportSpan(ended)
+	}
+}
+
+// End all open spans and export them. This should be invoked after the
+// service is stopped so that peers and interfaces still present are not lost.
+func (t *Tracer) Flush() {
+
+	// Collect the open spans.
+	t.mutex.Lock()
+	var spans []*Span
+	for id := range t.peers {
+		spans = append(spans, t.endPeer(id))
+	}
+	for name, s := range t.interfaces {
+		spans = append(spans, s)
+		delete(t.interfaces, name)
+	}
+	t.mutex.Unlock()
+
+	for _, s := range spans {
+		t.exporter.ExportSpan(s)
+	}
+}
@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery"
+	"github.com/nathan-osman/go-sdiscovery/comm/commtest"
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+var (
+	testTime1 = time.Unix(100, 0)
+	testTime2 = time.Unix(200, 0)
+)
+
+// Ensure that the lifecycle of a peer is recorded as a single span.
+func Test_Tracer_Peer(t *testing.T) {
+
+	e := &InMemoryExporter{}
+	tr := NewTracer(e)
+	for _, v := range []sdiscovery.Event{
+		{Type: sdiscovery.EventPeerAdded, Time: testTime1, PeerID: "a", Interface: "eth0", Addr: net.IPv4(192, 168, 1, 1)},
+		{Type: sdiscovery.EventPacketReceived, Time: testTime1, PeerID: "a"},
+		{Type: sdiscovery.EventPacketReceived, Time: testTime1, PeerID: "a"},
+		{Type: sdiscovery.EventPeerUpdated, Time: testTime1, PeerID: "a"},
+		{Type: sdiscovery.EventPacketReceived, Time: testTime1, PeerID: "a"},
+		{Type: sdiscovery.EventPeerRemoved, Time: testTime2, PeerID: "a"},
+	} {
+		tr.HandleEvent(v)
+	}
+
+	spans := e.Spans()
+	if len(spans) != 1 {
+		t.Fatal("Expected exactly one span")
+	}
+	s := spans[0]
+	if s.Name != "peer" || s.Attributes["peer"] != "a" || s.Attributes["packets"] != "3" ||
+		!s.Start.Equal(testTime1) || !s.End.Equal(testTime2) {
+		t.Fatal("Span is incorrect")
+	}
+	if len(s.Events) != 3 || s.Events[0].Attributes["addr"] != "192.168.1.1" ||
+		s.Events[1].Name != "peer_updated" {
+		t.Fatal("Span events are incorrect")
+	}
+}
+
+// Ensure that every packet received from a peer by a service is counted,
+// including the one that added the peer.
+func Test_Tracer_Service(t *testing.T) {
+
+	e := &InMemoryExporter{}
+	tr := NewTracer(e)
+	packets := make(chan struct{}, 10)
+	c := util.NewFakeClock(testTime1)
+	n := commtest.NewNetwork(0)
+	a := sdiscovery.New(sdiscovery.ServiceConfig{
+		PingInterval: time.Second,
+		PeerTimeout:  10 * time.Second,
+		ID:           "a",
+		Transport:    n.NewNode("a", "eth0"),
+		Clock:        c,
+		Hook: sdiscovery.HookFunc(func(v sdiscovery.Event) {
+			tr.HandleEvent(v)
+			if v.Type == sdiscovery.EventPacketReceived {
+				packets <- struct{}{}
+			}
+		}),
+	})
+	b := sdiscovery.New(sdiscovery.ServiceConfig{
+		PingInterval: time.Second,
+		PeerTimeout:  10 * time.Second,
+		ID:           "b",
+		Transport:    n.NewNode("b", "eth0"),
+		Clock:        c,
+	})
+	defer b.Stop()
+
+	// Have b send three pings, waiting for each to be received.
+	c.BlockUntil(4)
+	for i := 0; i < 3; i++ {
+		c.Advance(time.Second)
+		if i == 0 {
+			<-a.PeerAdded
+			<-b.PeerAdded
+		}
+		select {
+		case <-packets:
+		case <-time.After(time.Second):
+			t.Fatal("Packet was not received")
+		}
+	}
+
+	a.Stop()
+	tr.Flush()
+	for _, s := range e.Spans() {
+		if s.Name == "peer" {
+			if s.Attributes["packets"] != "3" {
+				t.Fatalf("Expected 3 packets, got %s", s.Attributes["packets"])
+			}
+			return
+		}
+	}
+	t.Fatal("Expected peer span")
+}
+
+// Ensure that errors are recorded on the span for their interface and that
+// open spans are exported when flushed.
+func Test_Tracer_Interface(t *testing.T) {
+
+	e := &InMemoryExporter{}
+	tr := NewTracer(e)
+	tr.HandleEvent(sdiscovery.Event{Type: sdiscovery.EventInterfaceAdded, Time: testTime1, Interface: "eth0"})
+	tr.HandleEvent(sdiscovery.Event{Type: sdiscovery.EventError, Time: testTime1, Interface: "eth0", Err: errors.New("a")})
+	tr.HandleEvent(sdiscovery.Event{Type: sdiscovery.EventError, Time: testTime1, Err: errors.New("b")})
+
+	// The error for the unknown interface is exported immediately.
+	if spans := e.Spans(); len(spans) != 1 || spans[0].Name != "error" {
+		t.Fatal("Expected error span")
+	}
+	e.Reset()
+
+	tr.Flush()
+	spans := e.Spans()
+	if len(spans) != 1 || spans[0].Name != "interface" || !spans[0].End.IsZero() {
+		t.Fatal("Expected open interface span")
+	}
+	if len(spans[0].Events) != 1 || spans[0].Events[0].Attributes["error"] != "a" {
+		t.Fatal("Error was not recorded on interface span")
+	}
+}
+
+// Ensure that the number of events recorded on a span is limited.
+func Test_Tracer_maxSpanEvents(t *testing.T) {
+
+	e := &InMemoryExporter{}
+	tr := NewTracer(e)
+	tr.HandleEvent(sdiscovery.Event{Type: sdiscovery.EventInterfaceAdded, Time: testTime1, Interface: "eth0"})
+	for i := 0; i < maxSpanEvents+5; i++ {
+		tr.HandleEvent(sdiscovery.Event{Type: sdiscovery.EventError, Time: testTime1, Interface: "eth0", Err: errors.New("a")})
+	}
+
+	tr.Flush()
+	spans := e.Spans()
+	if len(spans) != 1 || len(spans[0].Events) != maxSpanEvents || spans[0].Attributes["dropped_events"] != "5" {
+		t.Fatal("Events were not limited")
+	}
+}
@@ -51,3 +51,23 @@ func FindBroadcastAddress(ifi *net.Interface) (net.IP, error) {
 
 	return nil, errors.New("No broadcast address was found")
 }
+
+// Find all IPv4 networks that the provided network interface belongs to.
+func FindIPv4Networks(ifi *net.Interface) ([]*net.IPNet, error) {
+
+	// Obtain all of the addresses on the interface.
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep each of the addresses that is an IPv4 network.
+	var networks []*net.IPNet
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			networks = append(networks, ipNet)
+		}
+	}
+
+	return networks, nil
+}
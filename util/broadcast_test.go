@@ -25,3 +25,18 @@ func Test_BroadcastIPFromCIDR(t *testing.T) {
 		t.Fatal("Expected error for IPv6 address")
 	}
 }
+
+// Ensure that only IPv4 networks are returned for an interface.
+func Test_FindIPv4Networks(t *testing.T) {
+
+	// Use the addresses on every interface.
+	networks, err := FindIPv4Networks(&net.Interface{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, network := range networks {
+		if network.IP.To4() == nil {
+			t.Fatal("Expected only IPv4 networks")
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"time"
+)
+
+// Ticker delivers the time on a channel at regular intervals.
+type Ticker interface {
+	C() <-chan time.Time // channel on which the ticks are delivered
+	Stop()               // stop delivering ticks
+}
+
+// Clock provides the current time and timers. Using a Clock instead of the
+// time package directly allows the passage of time to be controlled in tests.
+type Clock interface {
+	Now() time.Time                         // current time
+	After(d time.Duration) <-chan time.Time // deliver the time once d has elapsed
+	NewTicker(d time.Duration) Ticker       // deliver the time every d
+}
+
+// SystemClock is a Clock that uses the time package.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) NewTicker(d time.Duration) Ticker       { return systemTicker{time.NewTicker(d)} }
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"net"
+)
+
+// Create a copy of a byte slice. A nil slice results in a nil copy.
+func CopyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// Create a copy of an IP address so that it does not share memory with the
+// original.
+func CopyIP(ip net.IP) net.IP {
+	return net.IP(CopyBytes(ip))
+}
+
+// Create a copy of a slice of strings. A nil slice results in a nil copy.
+func CopyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
+
+// Create a copy of a map of strings. A nil map results in a nil copy.
+func CopyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"net"
+	"testing"
+)
+
+// Ensure that copies do not share memory with the original.
+func Test_Copy(t *testing.T) {
+
+	// Copy a byte slice and modify the original.
+	b := []byte{1}
+	bCopy := CopyBytes(b)
+	b[0] = 2
+	if bCopy[0] != 1 {
+		t.Fatal("Byte slice was not copied")
+	}
+
+	// Copy an IP address and modify the original.
+	ip := net.IPv4(10, 0, 0, 1)
+	ipCopy := CopyIP(ip)
+	ip[15] = 2
+	if !ipCopy.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Fatal("IP address was not copied")
+	}
+
+	// Copy a map and modify the original.
+	m := map[string]string{"a": "1"}
+	mCopy := CopyStringMap(m)
+	m["a"] = "2"
+	if mCopy["a"] != "1" {
+		t.Fatal("Map was not copied")
+	}
+
+	// Copy a slice of strings and modify the original.
+	strs := []string{"a"}
+	strsCopy := CopyStrings(strs)
+	strs[0] = "b"
+	if strsCopy[0] != "a" {
+		t.Fatal("String slice was not copied")
+	}
+
+	// Nil values should remain nil.
+	if CopyBytes(nil) != nil || CopyStrings(nil) != nil || CopyStringMap(nil) != nil {
+		t.Fatal("Expected nil copies")
+	}
+}
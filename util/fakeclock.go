@@ -0,0 +1,153 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// fakeTimer is a timer or ticker registered with a FakeClock.
+type fakeTimer struct {
+	clock  *FakeClock
+	when   time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+// FakeClock is a Clock whose time only changes when Advance() is invoked. It
+// is intended for use in tests so that timeouts can be verified without
+// waiting for them to elapse.
+type FakeClock struct {
+	mutex      sync.Mutex
+	now        time.Time
+	timers     []*fakeTimer
+	changeChan chan struct{}
+}
+
+// Create a new fake clock set to the specified time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now:        now,
+		changeChan: make(chan struct{}),
+	}
+}
+
+// Register a new timer with the clock.
+func (f *FakeClock) newTimer(d, period time.Duration) *fakeTimer {
+
+	// Obtain exclusive access to the clock.
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// Like time.Ticker, the channel only holds a single tick.
+	t := &fakeTimer{
+		clock:  f,
+		when:   f.now.Add(d),
+		period: period,
+		c:      make(chan time.Time, 1),
+	}
+	f.timers = append(f.timers, t)
+
+	// Wake anything waiting in BlockUntil().
+	close(f.changeChan)
+	f.changeChan = make(chan struct{})
+
+	return t
+}
+
+// Remove a timer from the clock. The clock must be locked.
+func (f *FakeClock) removeTimer(t *fakeTimer) {
+	for i, other := range f.timers {
+		if other == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Obtain the current time of the clock.
+func (f *FakeClock) Now() time.Time {
+
+	// Obtain exclusive access to the clock.
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.now
+}
+
+// Deliver the time once the clock has been advanced by at least d.
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	return f.newTimer(d, 0).c
+}
+
+// Deliver the time each time the clock advances by d.
+func (f *FakeClock) NewTicker(d time.Duration) Ticker {
+	return f.newTimer(d, d)
+}
+
+// Advance the clock by the specified duration, firing each of the timers that
+// expire in the order that they expire.
+func (f *FakeClock) Advance(d time.Duration) {
+
+	// Obtain exclusive access to the clock.
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	target := f.now.Add(d)
+	for {
+
+		// Find the timer that expires first.
+		var next *fakeTimer
+		for _, t := range f.timers {
+			if !t.when.After(target) && (next == nil || t.when.Before(next.when)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		// Move the clock forward and fire the timer. Ticks are dropped if
+		// the previous one has not been received.
+		f.now = next.when
+		select {
+		case next.c <- f.now:
+		default:
+		}
+
+		// Reschedule tickers and remove timers.
+		if next.period != 0 {
+			next.when = next.when.Add(next.period)
+		} else {
+			f.removeTimer(next)
+		}
+	}
+	f.now = target
+}
+
+// Block until at least n timers and tickers are registered with the clock.
+// This is useful for ensuring that a goroutine has created its timers before
+// advancing the clock.
+func (f *FakeClock) BlockUntil(n int) {
+	for {
+		f.mutex.Lock()
+		changeChan := f.changeChan
+		numTimers := len(f.timers)
+		f.mutex.Unlock()
+		if numTimers >= n {
+			return
+		}
+		<-changeChan
+	}
+}
+
+// Obtain the channel on which ticks are delivered.
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop the ticker from delivering any more ticks.
+func (t *fakeTimer) Stop() {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	t.clock.removeTimer(t)
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+// Ensure that timers and tickers fire only when the clock is advanced.
+func Test_FakeClock(t *testing.T) {
+
+	f := NewFakeClock(time.Unix(0, 0))
+	after := f.After(2 * time.Second)
+	ticker := f.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// Nothing should fire before the clock is advanced.
+	select {
+	case <-after:
+		t.Fatal("Timer fired early")
+	case <-ticker.C():
+		t.Fatal("Ticker fired early")
+	default:
+	}
+
+	// Advancing by one second should fire the ticker only.
+	f.Advance(time.Second)
+	if v := <-ticker.C(); !v.Equal(time.Unix(1, 0)) {
+		t.Fatal("Incorrect tick time")
+	}
+	select {
+	case <-after:
+		t.Fatal("Timer fired early")
+	default:
+	}
+
+	// Advancing again should fire both.
+	f.Advance(time.Second)
+	<-ticker.C()
+	if v := <-after; !v.Equal(time.Unix(2, 0)) {
+		t.Fatal("Incorrect timer time")
+	}
+	if !f.Now().Equal(time.Unix(2, 0)) {
+		t.Fatal("Incorrect current time")
+	}
+}
+
+// Ensure that BlockUntil() returns once enough timers are registered.
+func Test_FakeClock_BlockUntil(t *testing.T) {
+
+	f := NewFakeClock(time.Unix(0, 0))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		f.After(time.Second)
+	}()
+	f.BlockUntil(1)
+}
@@ -0,0 +1,68 @@
+package util
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// Logger receives diagnostic messages from the library. Each message is
+// followed by alternating keys and values that describe it, such as the name
+// of an interface or the ID of a peer. *slog.Logger satisfies this interface.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Create a Logger that writes records to the specified slog.Handler.
+func NewSlogLogger(h slog.Handler) Logger {
+	return slog.New(h)
+}
+
+// DefaultLogger writes warnings and errors to the standard log package,
+// prefixed with their level. Debug and info messages are discarded.
+var DefaultLogger Logger = stdLogger{}
+
+// DiscardLogger discards all messages.
+var DiscardLogger Logger = discardLogger{}
+
+// Write messages to the standard log package.
+type stdLogger struct{}
+
+func (stdLogger) Debug(msg string, args ...any) {}
+func (stdLogger) Info(msg string, args ...any)  {}
+
+func (stdLogger) Warn(msg string, args ...any) {
+	log.Println(formatMessage("[WARN]", msg, args))
+}
+
+func (stdLogger) Error(msg string, args ...any) {
+	log.Println(formatMessage("[ERR]", msg, args))
+}
+
+// Format a message and its key/value pairs on a single line.
+func formatMessage(prefix, msg string, args []any) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v", args[i])
+		}
+	}
+	return b.String()
+}
+
+// Discard all messages.
+type discardLogger struct{}
+
+func (discardLogger) Debug(msg string, args ...any) {}
+func (discardLogger) Info(msg string, args ...any)  {}
+func (discardLogger) Warn(msg string, args ...any)  {}
+func (discardLogger) Error(msg string, args ...any) {}
@@ -0,0 +1,35 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// Ensure that messages are formatted with their fields.
+func Test_formatMessage(t *testing.T) {
+	for _, v := range []struct {
+		args []any
+		out  string
+	}{
+		{nil, "[WARN] msg"},
+		{[]any{"interface", "eth0"}, "[WARN] msg interface=eth0"},
+		{[]any{"error", errors.New("failed"), "odd"}, "[WARN] msg error=failed odd"},
+	} {
+		if s := formatMessage("[WARN]", "msg", v.args); s != v.out {
+			t.Fatalf("%q != %q", s, v.out)
+		}
+	}
+}
+
+// Ensure that the slog adapter writes structured fields.
+func Test_NewSlogLogger(t *testing.T) {
+	var b bytes.Buffer
+	l := NewSlogLogger(slog.NewTextHandler(&b, nil))
+	l.Warn("msg", "interface", "eth0")
+	if !strings.Contains(b.String(), "interface=eth0") {
+		t.Fatal("Field missing from output")
+	}
+}
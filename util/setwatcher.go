@@ -0,0 +1,150 @@
+package util
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// ChangeType indicates how an entry in a set changed.
+type ChangeType int
+
+const (
+	EntryAdded   ChangeType = iota // the key was not previously present
+	EntryRemoved                   // the key is no longer present
+	EntryChanged                   // the value for the key is different
+)
+
+// Change describes a single entry that was added, removed or changed. For
+// removals, Value contains the last value seen for the key. For changes, Old
+// contains the previous value.
+type Change[K comparable, V any] struct {
+	Type  ChangeType
+	Key   K
+	Value V
+	Old   V
+}
+
+// SetWatcher periodically invokes a function that enumerates a set of
+// key/value pairs and reports entries that were added, removed or changed.
+// All changes found in an enumeration are delivered as a single slice.
+type SetWatcher[K comparable, V any] struct {
+	Changes chan []Change[K, V] // notify when entries change
+	enum    func() (map[K]V, error)
+	equal   func(a, b V) bool
+	logger  Logger
+}
+
+// Create a new watcher with the specified enumeration function. The initial
+// enumeration takes place immediately and is reported as a set of additions.
+// Subsequent enumerations run each time a value is received from trigger
+// until it is closed or ctx is cancelled, at which point Changes is closed.
+// If equal is nil, values are compared with reflect.DeepEqual. Enumeration
+// errors are written to logger, or DefaultLogger if it is nil. Note that enum
+// must not modify the map it returns.
+func NewSetWatcher[K comparable, V any](ctx context.Context, trigger <-chan time.Time, enum func() (map[K]V, error), equal func(a, b V) bool, logger Logger) *SetWatcher[K, V] {
+
+	// Compare values deeply if no function was provided.
+	if equal == nil {
+		equal = func(a, b V) bool {
+			return reflect.DeepEqual(a, b)
+		}
+	}
+
+	// Use the default logger if none was provided.
+	if logger == nil {
+		logger = DefaultLogger
+	}
+
+	// Create the watcher.
+	s := &SetWatcher[K, V]{
+		Changes: make(chan []Change[K, V]),
+		enum:    enum,
+		equal:   equal,
+		logger:  logger,
+	}
+
+	// Launch a separate goroutine to perform the enumeration.
+	go s.run(ctx, trigger)
+
+	return s
+}
+
+// Continually invoke the enumeration function until stopped.
+func (s *SetWatcher[K, V]) run(ctx context.Context, trigger <-chan time.Time) {
+
+	defer close(s.Changes)
+
+	// Keep track of the entries that the receiver knows about and the most
+	// recent enumeration. Changes are always computed between the two so
+	// that entries changing several times between deliveries are coalesced.
+	var (
+		delivered = make(map[K]V)
+		current   = s.enumerate(delivered)
+		pending   = diff(delivered, current, s.equal)
+	)
+
+	for {
+
+		// Only attempt to deliver changes if there are any. A nil channel
+		// is never selected.
+		var changes chan<- []Change[K, V]
+		if len(pending) != 0 {
+			changes = s.Changes
+		}
+
+		select {
+		case changes <- pending:
+			delivered, pending = current, nil
+		case _, ok := <-trigger:
+			if !ok {
+				return
+			}
+			current = s.enumerate(current)
+			pending = diff(delivered, current, s.equal)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Invoke the enumeration function, returning the previous map on error.
+func (s *SetWatcher[K, V]) enumerate(previous map[K]V) map[K]V {
+	return enumerate(s.enum, previous, s.logger)
+}
+
+// Invoke an enumeration function, logging any error and returning the
+// previous map instead.
+func enumerate[K comparable, V any](enum func() (map[K]V, error), previous map[K]V, logger Logger) map[K]V {
+	m, err := enum()
+	if err != nil {
+		logger.Error("enumeration failed", "error", err)
+		return previous
+	}
+	return m
+}
+
+// Compare two maps and return a list of changes from a to b.
+func diff[K comparable, V any](a, b map[K]V, equal func(a, b V) bool) []Change[K, V] {
+
+	var changes []Change[K, V]
+
+	// Check each of the items in map b to see if they exist in map a and if
+	// so, whether the value has changed.
+	for k, bValue := range b {
+		if aValue, exists := a[k]; !exists {
+			changes = append(changes, Change[K, V]{Type: EntryAdded, Key: k, Value: bValue})
+		} else if !equal(aValue, bValue) {
+			changes = append(changes, Change[K, V]{Type: EntryChanged, Key: k, Value: bValue, Old: aValue})
+		}
+	}
+
+	// Check each of the items in map a to see if they were removed.
+	for k, aValue := range a {
+		if _, exists := b[k]; !exists {
+			changes = append(changes, Change[K, V]{Type: EntryRemoved, Key: k, Value: aValue})
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Attempt to read a batch of changes from the watcher.
+func readChanges(w *SetWatcher[string, int]) []Change[string, int] {
+	select {
+	case changes := <-w.Changes:
+		return changes
+	case <-time.After(1 * time.Second):
+		return nil
+	}
+}
+
+// Map that can be replaced while being enumerated from another goroutine.
+type testMap struct {
+	mutex sync.Mutex
+	m     map[string]int
+}
+
+func (t *testMap) set(m map[string]int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.m = m
+}
+
+func (t *testMap) enumerate() (map[string]int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.m, nil
+}
+
+// Ensure that additions, changes and removals are reported in batches.
+func Test_SetWatcher(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Create a watcher that returns the current value of the map.
+	trigger := make(chan time.Time)
+	m := &testMap{m: map[string]int{"a": 1, "b": 2}}
+	w := NewSetWatcher(ctx, trigger, m.enumerate, nil, DiscardLogger)
+
+	// The initial entries should be reported as a single batch.
+	if changes := readChanges(w); len(changes) != 2 ||
+		changes[0].Type != EntryAdded || changes[1].Type != EntryAdded {
+		t.Fatal("Expected two additions")
+	}
+
+	// Change one value and remove the other.
+	m.set(map[string]int{"a": 3})
+	trigger <- time.Now()
+	changes := readChanges(w)
+	if len(changes) != 2 {
+		t.Fatal("Expected two changes")
+	}
+	for _, c := range changes {
+		switch {
+		case c.Key == "a" && c.Type == EntryChanged && c.Value == 3 && c.Old == 1:
+		case c.Key == "b" && c.Type == EntryRemoved && c.Value == 2:
+		default:
+			t.Fatalf("Unexpected change %+v", c)
+		}
+	}
+}
+
+// Ensure that changes reverted before delivery are coalesced and that
+// cancellation closes the channel.
+func Test_SetWatcher_Coalesce(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	trigger := make(chan time.Time)
+	m := &testMap{m: map[string]int{}}
+	w := NewSetWatcher(ctx, trigger, m.enumerate, nil, DiscardLogger)
+
+	// Add and then remove an entry without reading any changes.
+	m.set(map[string]int{"a": 1})
+	trigger <- time.Now()
+	m.set(map[string]int{})
+	trigger <- time.Now()
+
+	// Add a different entry; only it should be reported.
+	m.set(map[string]int{"b": 1})
+	trigger <- time.Now()
+	if changes := readChanges(w); len(changes) != 1 || changes[0].Key != "b" {
+		t.Fatal("Expected only the second entry")
+	}
+
+	// Cancelling should close the channel.
+	cancel()
+	if _, ok := <-w.Changes; ok {
+		t.Fatal("Expected channel to be closed")
+	}
+}
@@ -1,9 +1,6 @@
 package util
 
 import (
-	"container/list"
-	"log"
-	"reflect"
 	"time"
 )
 
@@ -15,6 +12,10 @@ type EnumFunc func() (StrMap, error)
 
 // StrEnum provides a simple mechanism for periodically invoking a function
 // that enumerates strings and indicating when items are added or removed.
+//
+// StrEnum delivers each change on a separate channel. New code should use
+// SetWatcher instead, which delivers all changes at once along with their
+// values.
 type StrEnum struct {
 	StringAdded   chan string // notify when a string is added
 	StringRemoved chan string // notify when a string is removed
@@ -24,6 +25,17 @@ type StrEnum struct {
 // enumeration process will run each time a value is received from enumChan
 // until it is closed. Note that enumFunc must not modify the map it returns.
 func NewStrEnum(enumChan <-chan time.Time, enumFunc EnumFunc) *StrEnum {
+	return NewStrEnumWithLogger(enumChan, enumFunc, nil)
+}
+
+// Create a new enumerator that writes enumeration errors to logger instead of
+// DefaultLogger.
+func NewStrEnumWithLogger(enumChan <-chan time.Time, enumFunc EnumFunc, logger Logger) *StrEnum {
+
+	// Use the default logger if none was provided.
+	if logger == nil {
+		logger = DefaultLogger
+	}
 
 	// Create a new enumerator
 	s := &StrEnum{
@@ -32,106 +44,63 @@ func NewStrEnum(enumChan <-chan time.Time, enumFunc EnumFunc) *StrEnum {
 	}
 
 	// Launch a separate goroutine to perform the enumeration
-	go s.run(enumChan, enumFunc)
+	go s.run(enumChan, enumFunc, logger)
 
 	return s
 }
 
 // Continually invoke the enumerator until stopped.
-func (s *StrEnum) run(enumChan <-chan time.Time, enumFunc EnumFunc) {
-
-	// Lists of items that need to be sent on one of the notification channels.
-	stringsAdded, stringsRemoved := list.New(), list.New()
-
-	// Load the initial values into the map.
-	oldStrings, err := enumFunc()
-	if err != nil {
-		log.Println("[ERR]", err)
-	} else {
-		for item, _ := range oldStrings {
-			stringsAdded.PushBack(item)
-		}
+func (s *StrEnum) run(enumChan <-chan time.Time, enumFunc EnumFunc, logger Logger) {
+
+	defer close(s.StringAdded)
+	defer close(s.StringRemoved)
+
+	// Only additions and removals are reported, so values are never
+	// considered to have changed.
+	equal := func(a, b interface{}) bool {
+		return true
+	}
+	enum := func() (map[string]interface{}, error) {
+		return enumFunc()
 	}
 
+	// Load the initial values and treat them as additions.
+	oldStrings := enumerate(enum, map[string]interface{}{}, logger)
+	pending := diff(map[string]interface{}{}, oldStrings, equal)
+
 	for {
 
-		// Use constants to make it a bit easier to see what's going on.
-		const (
-			enumCase = iota
-			addCase
-			removeCase
-			numCases
+		// If a change is waiting to be sent, enable the appropriate channel
+		// in the select below. A nil channel is never selected. Since
+		// sending can block, the select also needs to include enumChan so
+		// that the send can be aborted if enumChan is closed.
+		var (
+			changeChan chan<- string
+			change     string
 		)
-
-		// A runtime select is needed here in order to avoid a deadlock.
-		// Whenever enumeration indicates that notifications should be sent,
-		// this needs to take place within the same select as enumChan. Since
-		// sending on these channels can block, there needs to be a way to
-		// abort the send if the enumChan is closed.
-		cases := make([]reflect.SelectCase, numCases)
-		cases[enumCase].Dir = reflect.SelectRecv
-		cases[enumCase].Chan = reflect.ValueOf(enumChan)
-		cases[addCase].Dir = reflect.SelectSend
-		cases[removeCase].Dir = reflect.SelectSend
-
-		// If there are values waiting to be sent on either of the two channels
-		// then fill in the appropriate fields in each of the cases.
-		if stringsAdded.Len() != 0 {
-			cases[addCase].Chan = reflect.ValueOf(s.StringAdded)
-			cases[addCase].Send = reflect.ValueOf(stringsAdded.Front().Value.(string))
-		}
-		if stringsRemoved.Len() != 0 {
-			cases[removeCase].Chan = reflect.ValueOf(s.StringRemoved)
-			cases[removeCase].Send = reflect.ValueOf(stringsRemoved.Front().Value.(string))
+		if len(pending) != 0 {
+			switch pending[0].Type {
+			case EntryAdded:
+				changeChan = s.StringAdded
+			case EntryRemoved:
+				changeChan = s.StringRemoved
+			}
+			change = pending[0].Key
 		}
 
-		// Perform the select. The value received is ignored.
-		i, _, ok := reflect.Select(cases)
-
-		// Perform the appropriate action based on the case index.
-		switch i {
-		case enumCase:
+		select {
+		case changeChan <- change:
+			pending = pending[1:]
+		case _, ok := <-enumChan:
 
 			// If the receive was successful, perform another enumeration.
 			// Otherwise, the channel was closed and the loop should quit.
-			if ok {
-
-				// Log and ignore any errors
-				if newStrings, err := enumFunc(); err != nil {
-					log.Println("[ERR]", err)
-				} else {
-					stringsAdded.PushBackList(s.compare(oldStrings, newStrings))
-					stringsRemoved.PushBackList(s.compare(newStrings, oldStrings))
-					oldStrings = newStrings
-				}
-			} else {
-				break
+			if !ok {
+				return
 			}
-
-		case addCase:
-			stringsAdded.Remove(stringsAdded.Front())
-		case removeCase:
-			stringsRemoved.Remove(stringsRemoved.Front())
+			newStrings := enumerate(enum, oldStrings, logger)
+			pending = append(pending, diff(oldStrings, newStrings, equal)...)
+			oldStrings = newStrings
 		}
 	}
-
-	close(s.StringAdded)
-	close(s.StringRemoved)
-}
-
-// Compare two maps and return a list of any changes.
-func (s *StrEnum) compare(a, b StrMap) *list.List {
-
-	// Create an empty list for new items.
-	l := list.New()
-
-	// Check each of the items in map b to see if they exist in map a. If not,
-	// add them to the list.
-	for item, _ := range b {
-		if _, exists := a[item]; !exists {
-			l.PushBack(item)
-		}
-	}
-
-	return l
 }
@@ -0,0 +1,49 @@
+package sdiscovery
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrServiceStopped is returned when waiting for a peer is interrupted by the
+// service being stopped.
+var ErrServiceStopped = errors.New("Service stopped")
+
+// Block until a peer satisfying the predicate is found and return it. If a
+// matching peer already exists, it is returned immediately. A nil predicate
+// matches any peer.
+func (s *Service) WaitForPeer(ctx context.Context, pred func(PeerInfo) bool) (PeerInfo, error) {
+	infos, err := s.WaitForPeers(ctx, 1, pred)
+	if err != nil {
+		return PeerInfo{}, err
+	}
+	return infos[0], nil
+}
+
+// Block until at least n peers satisfying the predicate are found and return
+// all of the peers that match. If enough matching peers already exist, they
+// are returned immediately. A nil predicate matches any peer.
+func (s *Service) WaitForPeers(ctx context.Context, n int, pred func(PeerInfo) bool) ([]PeerInfo, error) {
+	for {
+
+		// Grab the change channel before querying so that no changes are
+		// missed between the query and the select below.
+		s.mutex.Lock()
+		changeChan := s.changeChan
+		s.mutex.Unlock()
+
+		// Check the peers that currently exist.
+		if infos := s.Query(Query{Predicate: pred}); len(infos) >= n {
+			return infos, nil
+		}
+
+		// Wait for the map to change before checking again.
+		select {
+		case <-changeChan:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.stopChan:
+			return nil, ErrServiceStopped
+		}
+	}
+}
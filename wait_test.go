@@ -0,0 +1,88 @@
+package sdiscovery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sdiscovery/comm"
+	"github.com/nathan-osman/go-sdiscovery/metrics"
+	"github.com/nathan-osman/go-sdiscovery/util"
+)
+
+// Create a service that can process packets without starting it.
+func newWaitTestService() *Service {
+	return &Service{
+		PeerAdded:  make(chan string, 10),
+		stopChan:   make(chan interface{}),
+		changeChan: make(chan struct{}),
+		peers:      make(peerMap),
+		removed:    make(map[string]time.Time),
+		config: ServiceConfig{
+			Clock:   util.SystemClock,
+			Logger:  util.DiscardLogger,
+			Metrics: metrics.Discard,
+		},
+	}
+}
+
+// Create a predicate that matches peers with the specified role.
+func roleIs(role string) func(PeerInfo) bool {
+	return func(i PeerInfo) bool {
+		return i.Attributes["role"] == role
+	}
+}
+
+// Ensure that WaitForPeer() returns once a matching peer appears.
+func Test_Service_WaitForPeer(t *testing.T) {
+
+	s := newWaitTestService()
+
+	// Add a non-matching peer immediately and a matching one later.
+	s.processPacket(&comm.Packet{IP: net.IPv4(10, 0, 0, 1), ID: "1"})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.processPacket(&comm.Packet{
+			IP:         net.IPv4(10, 0, 0, 2),
+			ID:         "2",
+			Attributes: map[string]string{"role": "coordinator"},
+		})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Wait for the matching peer.
+	info, err := s.WaitForPeer(ctx, roleIs("coordinator"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != "2" {
+		t.Fatal("Incorrect peer returned")
+	}
+
+	// A second wait should return immediately.
+	if _, err := s.WaitForPeer(ctx, roleIs("coordinator")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that WaitForPeers() honors cancellation and stopping.
+func Test_Service_WaitForPeers_Cancel(t *testing.T) {
+
+	s := newWaitTestService()
+
+	// The context expiring should cause an error.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.WaitForPeers(ctx, 2, nil); err != context.DeadlineExceeded {
+		t.Fatal("Expected deadline to be exceeded")
+	}
+
+	// Stopping the service should also cause an error.
+	close(s.stopChan)
+	if _, err := s.WaitForPeers(context.Background(), 2, nil); err != ErrServiceStopped {
+		t.Fatal("Expected service stopped error")
+	}
+}